@@ -2,11 +2,9 @@ package api
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 )
 
@@ -19,13 +17,20 @@ type Client interface {
 	NewURL(endpoint string) (*url.URL, error)
 	NewRequestWithContext(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error)
 	Get(ctx context.Context, endpoint string) (*http.Response, error)
+	Post(ctx context.Context, endpoint string, body io.Reader) (*http.Response, error)
+	Put(ctx context.Context, endpoint string, body io.Reader) (*http.Response, error)
+	Patch(ctx context.Context, endpoint string, body io.Reader) (*http.Response, error)
+	Delete(ctx context.Context, endpoint string) (*http.Response, error)
 	Do(req *http.Request) (*http.Response, error)
 }
 
 type BaseClient struct {
 	httpClient *http.Client
-	host       string
-	base       string
+	host       *url.URL
+	base       *url.URL
+	err        error // from parsing the host or base
+	middleware []Middleware
+	hooks      *hooks
 	do         Dofn
 }
 
@@ -38,56 +43,133 @@ type BaseClient struct {
 // This is useful if you are using the "links" part of responses which already
 // have the base part in them.
 func NewClient(host, baseEndpoint string, timeout time.Duration, doers ...Middleware) *BaseClient {
-	client := &http.Client{
-		Timeout: timeout,
+	return New(host,
+		WithBase(baseEndpoint),
+		WithTimeout(timeout),
+		WithMiddleware(doers...),
+	)
+}
+
+// New creates a new BaseClient configured by the options.
+// It is the options based version of NewClient and the Middleware
+// passed with WithMiddleware run in the same order NewClient runs them.
+func New(host string, opts ...Option) *BaseClient {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
 	}
 
 	c := &BaseClient{
-		httpClient: client,
-		host:       host,
-		base:       baseEndpoint,
+		httpClient: cfg.buildHTTPClient(),
+		middleware: cfg.middleware,
+		hooks:      &cfg.hooks,
+	}
+
+	// parse these once so every request resolves against the same URLs
+	c.host, c.err = url.Parse(host)
+	if c.err == nil {
+		c.base, c.err = url.Parse(cfg.base)
 	}
 
-	//
-	// construct the Do func
-	//
+	c.do = c.buildDo()
+
+	return c
+}
+
+// With returns a new client that runs the given Middleware before the
+// existing chain. The new client shares the underlying http.Client, so
+// connections are pooled with the original, and the original client
+// is left unchanged.
+func (c *BaseClient) With(doers ...Middleware) *BaseClient {
+	derived := *c
 
+	derived.middleware = make([]Middleware, 0, len(doers)+len(c.middleware))
+	derived.middleware = append(derived.middleware, doers...)
+	derived.middleware = append(derived.middleware, c.middleware...)
+
+	derived.do = derived.buildDo()
+
+	return &derived
+}
+
+// buildDo constructs the Do func from the client's middleware
+func (c *BaseClient) buildDo() Dofn {
 	// start with the base Do func
-	do := c.httpClient.Do
+	// wrapped with the request, response and error hooks
+	do := c.hooks.wrap(c.httpClient.Do)
 
 	// apply the middleware Do funcs
 	// in reverse order so that then end up executing
 	// in the ordered they were passed in
-	for i := len(doers) - 1; i >= 0; i-- {
-		do = doers[i](do)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		do = c.middleware[i](do)
 	}
 
-	// use the wrapped Do func for the client
-	c.do = do
-
-	return c
+	// apply the per request options from the context
+	// and make the hooks available to the middleware
+	return c.hooks.attach(requestScoped(do))
 }
 
+// NewURL resolves the endpoint against the base endpoint of the client.
+// Absolute URLs are returned untouched and any query on the endpoint
+// is merged with the query of the base.
 func (c BaseClient) NewURL(endpoint string) (*url.URL, error) {
-	return url.Parse(c.base + endpoint)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return resolve(c.base, endpoint)
 }
 
 // NewRequestWithContext wraps the http version and sets the url.
 // This allows the endpoint being passed to not include the host or base part of the url.
+// Absolute URLs, like the "next" links some servers return, are used untouched.
 func (c BaseClient) NewRequestWithContext(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error) {
-	url := fmt.Sprintf(
-		"%s/%s",
-		strings.TrimRight(c.host, "/"),
-		strings.TrimLeft(endpoint, "/"),
-	)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	u, err := resolve(c.host, endpoint)
+	if err != nil {
+		return nil, err
+	}
 
-	return http.NewRequestWithContext(ctx, method, url, body)
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
 }
 
 // Get is similar to http.Client{}.Get except it uses the BearerTokenClient
 // and defaults to JSON as the payload to and from the server.
 func (c BaseClient) Get(ctx context.Context, endpoint string) (*http.Response, error) {
-	req, err := c.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	return c.send(ctx, http.MethodGet, endpoint, nil)
+}
+
+// Post builds a POST request for the endpoint with the given body
+// and sends it through the middleware chain.
+func (c BaseClient) Post(ctx context.Context, endpoint string, body io.Reader) (*http.Response, error) {
+	return c.send(ctx, http.MethodPost, endpoint, body)
+}
+
+// Put builds a PUT request for the endpoint with the given body
+// and sends it through the middleware chain.
+func (c BaseClient) Put(ctx context.Context, endpoint string, body io.Reader) (*http.Response, error) {
+	return c.send(ctx, http.MethodPut, endpoint, body)
+}
+
+// Patch builds a PATCH request for the endpoint with the given body
+// and sends it through the middleware chain.
+func (c BaseClient) Patch(ctx context.Context, endpoint string, body io.Reader) (*http.Response, error) {
+	return c.send(ctx, http.MethodPatch, endpoint, body)
+}
+
+// Delete builds a DELETE request for the endpoint
+// and sends it through the middleware chain.
+func (c BaseClient) Delete(ctx context.Context, endpoint string) (*http.Response, error) {
+	return c.send(ctx, http.MethodDelete, endpoint, nil)
+}
+
+// send is the shared implementation of the verb funcs
+func (c BaseClient) send(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
+	req, err := c.NewRequestWithContext(ctx, method, endpoint, body)
 	if err != nil {
 		return nil, err
 	}
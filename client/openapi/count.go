@@ -2,7 +2,6 @@ package openapi
 
 import (
 	"context"
-	"encoding/json"
 	"net/url"
 
 	"github.com/Reisender/go-api"
@@ -37,11 +36,10 @@ func Count(ctx context.Context, c api.Client, endpoint string, params Params) (i
 	defer res.Body.Close()
 
 	countResponse := struct {
-		Count int `json:"count"`
+		Count int `json:"count" xml:"count"`
 	}{}
 
-	dec := json.NewDecoder(res.Body)
-	err = dec.Decode(&countResponse)
+	err = api.DecodeResponse(res, &countResponse)
 	if err != nil {
 		return 0, err
 	}
@@ -21,8 +21,7 @@ func Lookup(ctx context.Context, c api.Client, endpoint string, resource interfa
 	}
 	defer res.Body.Close()
 	resp := &Response{}
-	dec := json.NewDecoder(res.Body)
-	err = dec.Decode(resp)
+	err = api.DecodeResponse(res, resp)
 	if err != nil {
 		return err
 	}
@@ -2,7 +2,6 @@ package openapi
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -42,9 +41,9 @@ func Paginate(ctx context.Context, c api.Client, endpoint string, params Params,
 
 		// parse the response
 		resp := &Response{}
-		dec := json.NewDecoder(res.Body)
-		err = dec.Decode(resp)
+		err = api.DecodeResponse(res, resp)
 		if err != nil {
+			res.Body.Close()
 			return err
 		}
 		err = res.Body.Close()
@@ -0,0 +1,139 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single server-sent event
+type Event struct {
+	ID    string        // the last event ID seen on the stream
+	Event string        // the event type, "message" if the server didn't set one
+	Data  string        // the data lines joined with "\n"
+	Retry time.Duration // the reconnection time if the event set one
+}
+
+// Reader parses events from a text/event-stream body
+type Reader struct {
+	scanner *bufio.Scanner
+	lastID  string
+	retry   time.Duration
+}
+
+// NewReader creates a Reader that parses events from r
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	scanner.Split(scanLines)
+
+	return &Reader{scanner: scanner}
+}
+
+// LastEventID is the last event ID seen on the stream.
+// It is sent as the Last-Event-ID header when reconnecting.
+func (r *Reader) LastEventID() string {
+	return r.lastID
+}
+
+// Retry is the last reconnection time the server sent, 0 if none was sent
+func (r *Reader) Retry() time.Duration {
+	return r.retry
+}
+
+// Next reads the next event from the stream.
+// It returns io.EOF when the stream ends, an event that is not finished
+// by a blank line before the end of the stream is dropped.
+func (r *Reader) Next() (Event, error) {
+	ev := Event{}
+	data := strings.Builder{}
+	hasData := false
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		// a blank line dispatches the event
+		if line == "" {
+			if !hasData {
+				// nothing to dispatch, start a new event
+				ev = Event{}
+				continue
+			}
+
+			ev.ID = r.lastID
+			ev.Data = data.String()
+			if ev.Event == "" {
+				ev.Event = "message"
+			}
+			return ev, nil
+		}
+
+		// lines starting with a colon are comments
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		case "retry":
+			ms, err := strconv.ParseUint(value, 10, 63)
+			if err == nil {
+				ev.Retry = time.Duration(ms) * time.Millisecond
+				r.retry = ev.Retry
+			}
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+
+	return Event{}, io.EOF
+}
+
+// scanLines is a bufio.SplitFunc for lines ending in "\r\n", "\n" or "\r"
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		// a "\r" may be followed by a "\n" we haven't read yet
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,158 @@
+package sse_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/client/sse"
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestReader(t *testing.T) {
+	stream := ": comment\r\n" +
+		"retry: 100\r\n" +
+		"\r\n" +
+		"event: update\r\n" +
+		"id: 1\r\n" +
+		"data: first\r\n" +
+		"data:second\r\n" +
+		"\r\n" +
+		"data: no type\r" +
+		"\r" +
+		"id: 2\n" +
+		"data: unfinished\n"
+
+	r := sse.NewReader(strings.NewReader(stream))
+
+	want := []sse.Event{
+		{ID: "1", Event: "update", Data: "first\nsecond"},
+		{ID: "1", Event: "message", Data: "no type"},
+	}
+
+	for _, w := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("want %+v got %+v", w, got)
+		}
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF for the unfinished event, got %v", err)
+	}
+
+	if r.Retry() != 100*time.Millisecond {
+		t.Errorf("retry: want 100ms got %v", r.Retry())
+	}
+}
+
+func TestSubscribeReconnects(t *testing.T) {
+	connections := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connections++
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		switch connections {
+		case 1:
+			fmt.Fprint(w, "retry: 1\n\nid: 1\ndata: one\n\nid: 2\ndata: two\n\n")
+		default:
+			if got := r.Header.Get("Last-Event-ID"); got != "2" {
+				t.Errorf("Last-Event-ID: want '2' got '%s'", got)
+			}
+			fmt.Fprint(w, "id: 3\ndata: three\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	c := api.New(srv.URL, api.WithBase("/v1"))
+
+	got := []string{}
+	err := sse.Subscribe(context.Background(), c, "/events", func(ev sse.Event) error {
+		got = append(got, ev.ID+":"+ev.Data)
+		if ev.ID == "3" {
+			return sse.ErrStopStream
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "1:one,2:two,3:three"; strings.Join(got, ",") != want {
+		t.Errorf("events: want '%s' got '%s'", want, strings.Join(got, ","))
+	}
+}
+
+func TestSubscribeStatusError(t *testing.T) {
+	connections := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connections++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		m    api.Middleware
+	}{
+		{"error on status codes", middleware.ErrorOnStatusCodes(middleware.ErrorStatusCodes)},
+		{"api error on status codes", middleware.APIErrorOnStatusCodes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connections = 0
+			c := api.New(srv.URL, api.WithMiddleware(tt.m))
+
+			err := sse.Subscribe(context.Background(), c, "/events", func(ev sse.Event) error {
+				return nil
+			}, sse.WithRetry(time.Millisecond))
+
+			var statusErr middleware.ErrStatusCode
+			if !errors.As(err, &statusErr) || statusErr.Code != http.StatusUnauthorized {
+				t.Errorf("expected a 401 ErrStatusCode, got %v", err)
+			}
+
+			if connections != 1 {
+				t.Errorf("connections: want 1 got %d", connections)
+			}
+		})
+	}
+}
+
+func TestEventsCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := sse.Events(ctx, api.New(srv.URL), "/events")
+
+	ev := <-events
+	if ev.Data != "hello" {
+		t.Errorf("data: want 'hello' got '%s'", ev.Data)
+	}
+
+	cancel()
+
+	for range events {
+	}
+
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+// ErrStopStream can be returned from the event handler to stop the stream
+var ErrStopStream = fmt.Errorf("stop stream")
+
+// DefaultRetry is the reconnection time used until the server sends one
+const DefaultRetry = 3 * time.Second
+
+// Option configures a stream
+type Option func(*stream)
+
+// WithLastEventID sets the Last-Event-ID sent on the first connection
+// to resume a stream from an earlier event.
+func WithLastEventID(id string) Option {
+	return func(s *stream) {
+		s.lastID = id
+	}
+}
+
+// WithRetry sets the reconnection time used until the server sends one
+func WithRetry(retry time.Duration) Option {
+	return func(s *stream) {
+		s.retry = retry
+	}
+}
+
+// WithMaxReconnects limits the number of reconnects in a row that don't
+// receive an event. The default of 0 reconnects until the context is done.
+func WithMaxReconnects(max int) Option {
+	return func(s *stream) {
+		s.maxReconnects = max
+	}
+}
+
+// stream is the state of a subscription kept across reconnects
+type stream struct {
+	client        api.Client
+	endpoint      string
+	lastID        string
+	retry         time.Duration
+	maxReconnects int
+}
+
+// Subscribe connects to the event stream at the endpoint and calls handle for each event.
+// The endpoint is resolved with the client's NewURL and the requests go through
+// the client's Do, so the client's middleware still apply.
+// When the connection drops it reconnects after the retry time, sending the
+// Last-Event-ID header so the server can resume the stream.
+// It returns when the context is done, handle returns an error, or the server
+// responds with something other than a 200 event stream. A 204 No Content ends
+// the stream without an error and ErrStopStream from handle is not passed on.
+// The http.Client timeout applies to the whole stream so it should be 0 for
+// long lived streams.
+func Subscribe(ctx context.Context, c api.Client, endpoint string, handle func(Event) error, opts ...Option) error {
+	s := &stream{
+		client:   c,
+		endpoint: endpoint,
+		retry:    DefaultRetry,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	err := s.run(ctx, handle)
+	if errors.Is(err, ErrStopStream) {
+		return nil // don't pass this error on
+	}
+
+	return err
+}
+
+// Events is the channel version of Subscribe.
+// The events channel is closed when the stream ends and the error channel
+// then receives the error, if any, that ended it.
+func Events(ctx context.Context, c api.Client, endpoint string, opts ...Option) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+
+		err := Subscribe(ctx, c, endpoint, func(ev Event) error {
+			select {
+			case events <- ev:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return events, errs
+}
+
+// run is the connect and reconnect loop
+func (s *stream) run(ctx context.Context, handle func(Event) error) error {
+	reconnects := 0
+
+	for {
+		received, err := s.connect(ctx, handle)
+		if received {
+			reconnects = 0
+		}
+
+		// stop for errors from the handler or the server
+		var connErr connectError
+		if err != nil && !errors.As(err, &connErr) {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		reconnects++
+		if s.maxReconnects > 0 && reconnects > s.maxReconnects {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("sse: max reconnects reached")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.retry):
+		}
+	}
+}
+
+// connectError is an error from the connection that can be retried
+type connectError struct {
+	err error
+}
+
+func (e connectError) Error() string {
+	return e.err.Error()
+}
+
+func (e connectError) Unwrap() error {
+	return e.err
+}
+
+// connect makes one connection and reads events until it ends.
+// It reports if any events were received on the connection.
+func (s *stream) connect(ctx context.Context, handle func(Event) error) (bool, error) {
+	u, err := s.client.NewURL(s.endpoint)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := s.client.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastID != "" {
+		req.Header.Set("Last-Event-ID", s.lastID)
+	}
+
+	res, err := s.client.Do(req)
+	if res != nil && res.Body != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		// a status error from the client's middleware ends the stream like the status check below
+		var statusErr middleware.ErrStatusCode
+		if errors.As(err, &statusErr) {
+			return false, err
+		}
+		return false, connectError{err}
+	}
+
+	if res.StatusCode == http.StatusNoContent {
+		return false, ErrStopStream
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return false, middleware.ErrStatusCode{Status: res.Status, Code: res.StatusCode}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		return false, fmt.Errorf("sse: unexpected content type %q", mediaType)
+	}
+
+	r := NewReader(res.Body)
+	r.lastID = s.lastID
+	received := false
+
+	for {
+		ev, err := r.Next()
+
+		// keep the stream state for the reconnect
+		s.lastID = r.LastEventID()
+		if retry := r.Retry(); retry > 0 {
+			s.retry = retry
+		}
+
+		if err != nil {
+			return received, connectError{err}
+		}
+
+		received = true
+		err = handle(ev)
+		if err != nil {
+			return received, err
+		}
+	}
+}
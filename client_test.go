@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/Reisender/go-api"
@@ -79,3 +80,174 @@ func TestNewClient(t *testing.T) {
 		t.Errorf("\nwant '%s'\ngot '%s'\n", wantBody, gotBody)
 	}
 }
+
+// roundTripFunc allows a func to be used as an http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNew(t *testing.T) {
+	order := []string{}
+	record := func(name string) api.Middleware {
+		return func(next api.Dofn) api.Dofn {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next(req)
+			}
+		}
+	}
+
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "transport")
+		return &http.Response{
+			StatusCode: 200,
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+
+	httpClient := &http.Client{}
+	c := api.New("http://localhost",
+		api.WithHTTPClient(httpClient),
+		api.WithTransport(transport),
+		api.WithMiddleware(record("first"), record("second")),
+		api.WithMiddleware(record("third")),
+	)
+
+	resp, err := c.Get(context.Background(), "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := "first,second,third,transport"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order: want '%s' got '%s'", want, got)
+	}
+
+	if httpClient.Transport != nil {
+		t.Error("the passed in http.Client should not be modified")
+	}
+}
+
+func TestNewURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		endpoint string
+		want     string
+	}{
+		{"no base", "", "/foo", "/foo"},
+		{"base", "/v1", "/foo", "/v1/foo"},
+		{"base trailing slash", "/v1/", "/foo", "/v1/foo"},
+		{"endpoint without leading slash", "/v1", "foo", "/v1/foo"},
+		{"keep trailing slash", "/v1", "/foo/", "/v1/foo/"},
+		{"endpoint query", "/v1", "/foo?limit=1", "/v1/foo?limit=1"},
+		{"merge queries", "/v1?key=abc", "/foo?limit=1", "/v1/foo?key=abc&limit=1"},
+		{"endpoint query wins", "/v1?limit=5", "/foo?limit=1", "/v1/foo?limit=1"},
+		{"query only", "/v1", "?limit=1", "/v1?limit=1"},
+		{"escaped path", "/v1", "/foo%2Fbar", "/v1/foo%2Fbar"},
+		{"escaped query", "/v1", "/foo?q=a%20b", "/v1/foo?q=a%20b"},
+		{"parent segment", "/v1", "../v2/foo", "/v2/foo"},
+		{"absolute", "/v1", "https://example.com/v3/foo?page=2", "https://example.com/v3/foo?page=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := api.New("https://localhost", api.WithBase(tt.base))
+
+			got, err := c.NewURL(tt.endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.String() != tt.want {
+				t.Errorf("want '%s' got '%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewRequestWithContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		endpoint string
+		want     string
+	}{
+		{"host", "https://localhost", "/foo", "https://localhost/foo"},
+		{"host trailing slash", "https://localhost/", "/foo", "https://localhost/foo"},
+		{"host with path", "https://localhost/api", "/v1/foo", "https://localhost/api/v1/foo"},
+		{"host with path trailing slash", "https://localhost/api/", "v1/foo", "https://localhost/api/v1/foo"},
+		{"host with port", "http://localhost:8080", "/foo", "http://localhost:8080/foo"},
+		{"host without scheme", "localhost", "/v1/foo", "localhost/v1/foo"},
+		{"empty endpoint", "https://localhost/api", "", "https://localhost/api"},
+		{"query", "https://localhost", "/foo?limit=1&starting_after=abc", "https://localhost/foo?limit=1&starting_after=abc"},
+		{"host query merged", "https://localhost?key=abc", "/foo?limit=1", "https://localhost/foo?key=abc&limit=1"},
+		{"escaped path", "https://localhost", "/files/a%2Fb", "https://localhost/files/a%2Fb"},
+		{"absolute", "https://localhost/api", "https://other.example.com/v3/foo?page=2", "https://other.example.com/v3/foo?page=2"},
+		{"scheme relative", "https://localhost/api", "//other.example.com/foo", "https://other.example.com/foo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := api.New(tt.host)
+
+			req, err := c.NewRequestWithContext(context.Background(), http.MethodGet, tt.endpoint, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if req.URL.String() != tt.want {
+				t.Errorf("want '%s' got '%s'", tt.want, req.URL)
+			}
+		})
+	}
+}
+
+func TestNewURLParseError(t *testing.T) {
+	c := api.New("https://localhost", api.WithBase("%zz"))
+
+	if _, err := c.NewURL("/foo"); err == nil {
+		t.Error("expected an error for an invalid base")
+	}
+
+	if _, err := c.Get(context.Background(), "/foo"); err == nil {
+		t.Error("expected an error for an invalid base")
+	}
+}
+
+func TestWith(t *testing.T) {
+	order := []string{}
+	record := func(name string) api.Middleware {
+		return func(next api.Dofn) api.Dofn {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next(req)
+			}
+		}
+	}
+
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "transport")
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: req}, nil
+	})
+
+	c := api.New("http://localhost", api.WithTransport(transport), api.WithMiddleware(record("base")))
+	derived := c.With(record("derived"))
+
+	for _, client := range []*api.BaseClient{derived, c} {
+		resp, err := client.Get(context.Background(), "/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	want := "derived,base,transport,base,transport"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order: want '%s' got '%s'", want, got)
+	}
+}
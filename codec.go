@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes payloads of a media type
+type Codec interface {
+	// MediaType is the value used for the Content-Type and Accept headers
+	MediaType() string
+
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// The built in codecs
+var (
+	JSON   Codec = jsonCodec{}
+	XML    Codec = xmlCodec{}
+	Form   Codec = formCodec{}
+	NDJSON Codec = ndjsonCodec{}
+)
+
+// codecs is the registry of codecs keyed by media type
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{
+	m: map[string]Codec{
+		"application/json":                  JSON,
+		"text/json":                         JSON,
+		"application/xml":                   XML,
+		"text/xml":                          XML,
+		"application/x-www-form-urlencoded": Form,
+		"application/x-ndjson":              NDJSON,
+		"application/ndjson":                NDJSON,
+	},
+}
+
+// RegisterCodec adds the codec to the registry for the media type.
+// It replaces any codec already registered for the media type.
+func RegisterCodec(mediaType string, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	codecs.m[strings.ToLower(mediaType)] = c
+}
+
+// CodecFor looks up the codec for a Content-Type value.
+// Parameters like charset are ignored and structured syntax suffixes
+// like application/problem+json fall back to the codec for application/json.
+func CodecFor(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	if c, ok := codecs.m[mediaType]; ok {
+		return c, true
+	}
+
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		c, ok := codecs.m["application/"+mediaType[i+1:]]
+		return c, ok
+	}
+
+	return nil, false
+}
+
+// Negotiate picks the codec for the headers.
+// The Content-Type is used first, then the most preferred Accept value that
+// has a codec. If neither has a registered codec the fallback is returned.
+func Negotiate(header http.Header, fallback Codec) Codec {
+	if c, ok := CodecFor(header.Get("Content-Type")); ok {
+		return c
+	}
+
+	for _, mediaType := range accepted(header.Values("Accept")) {
+		if c, ok := CodecFor(mediaType); ok {
+			return c
+		}
+	}
+
+	return fallback
+}
+
+// accepted returns the media types from Accept header values
+// ordered by their quality value
+func accepted(values []string) []string {
+	type accept struct {
+		mediaType string
+		q         float64
+	}
+
+	accepts := []accept{}
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+
+			if q > 0 {
+				accepts = append(accepts, accept{mediaType, q})
+			}
+		}
+	}
+
+	sort.SliceStable(accepts, func(i, j int) bool {
+		return accepts[i].q > accepts[j].q
+	})
+
+	mediaTypes := make([]string, len(accepts))
+	for i, a := range accepts {
+		mediaTypes[i] = a.mediaType
+	}
+
+	return mediaTypes
+}
+
+// DecodeResponse reads the response body and decodes it in to v with the codec
+// negotiated from the response's Content-Type, defaulting to JSON.
+// Decode failures are returned as a ParseError with the raw body.
+// The body is not closed.
+func DecodeResponse(res *http.Response, v interface{}) error {
+	return decodeResponse(res, v, JSON)
+}
+
+// decodeResponse is DecodeResponse with a fallback codec
+func decodeResponse(res *http.Response, v interface{}, fallback Codec) error {
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	codec := Negotiate(http.Header{"Content-Type": res.Header.Values("Content-Type")}, fallback)
+
+	err = codec.Decode(bytes.NewReader(raw), v)
+	if err != nil {
+		return ParseError{Raw: raw, Err: err}
+	}
+
+	return nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MediaType() string { return "application/json" }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) MediaType() string { return "application/xml" }
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
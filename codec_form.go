@@ -0,0 +1,194 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// formCodec is the application/x-www-form-urlencoded codec.
+// It handles url.Values, map[string][]string, map[string]string and structs.
+// Struct fields use the "form" tag for their name, "-" skips the field.
+type formCodec struct{}
+
+func (formCodec) MediaType() string { return "application/x-www-form-urlencoded" }
+
+func (formCodec) Encode(w io.Writer, v interface{}) error {
+	vals, err := formValues(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, vals.Encode())
+	return err
+}
+
+func (formCodec) Decode(r io.Reader, v interface{}) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	vals, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return err
+	}
+
+	switch dst := v.(type) {
+	case *url.Values:
+		*dst = vals
+		return nil
+	case *map[string][]string:
+		*dst = vals
+		return nil
+	case *map[string]string:
+		*dst = make(map[string]string, len(vals))
+		for k := range vals {
+			(*dst)[k] = vals.Get(k)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("form: can't decode in to %T", v)
+	}
+
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name, ok := formFieldName(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		fieldVals, ok := vals[name]
+		if !ok {
+			continue
+		}
+
+		err = setFormField(rv.Field(i), fieldVals)
+		if err != nil {
+			return fmt.Errorf("form: field %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// formValues converts v to url.Values
+func formValues(v interface{}) (url.Values, error) {
+	switch src := v.(type) {
+	case url.Values:
+		return src, nil
+	case map[string][]string:
+		return src, nil
+	case map[string]string:
+		vals := url.Values{}
+		for k, val := range src {
+			vals.Set(k, val)
+		}
+		return vals, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("form: can't encode %T", v)
+	}
+
+	vals := url.Values{}
+	for i := 0; i < rv.NumField(); i++ {
+		name, ok := formFieldName(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		field := rv.Field(i)
+		if field.Kind() == reflect.Slice || field.Kind() == reflect.Array {
+			for j := 0; j < field.Len(); j++ {
+				vals.Add(name, fmt.Sprint(field.Index(j).Interface()))
+			}
+			continue
+		}
+
+		vals.Set(name, fmt.Sprint(field.Interface()))
+	}
+
+	return vals, nil
+}
+
+// formFieldName returns the form name of the struct field
+// and false if the field should be skipped
+func formFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+
+	name := strings.Split(field.Tag.Get("form"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+
+	return name, true
+}
+
+// setFormField sets the field from the form values
+func setFormField(field reflect.Value, vals []string) error {
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			err := setFormValue(slice.Index(i), val)
+			if err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setFormValue(field, vals[0])
+}
+
+// setFormValue parses the string in to the value's kind
+func setFormValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ndjsonCodec is the newline delimited JSON codec.
+// Slices are encoded one element per line and decoding in to a pointer
+// to a slice appends one element per line.
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) MediaType() string { return "application/x-ndjson" }
+
+func (ndjsonCodec) Encode(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+
+	// encode anything that isn't a list as a single line
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return enc.Encode(v)
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		err := enc.Encode(rv.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ndjsonCodec) Decode(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("ndjson: can't decode in to %T", v)
+	}
+
+	slice := rv.Elem()
+	if slice.Kind() != reflect.Slice {
+		return dec.Decode(v)
+	}
+
+	for {
+		elem := reflect.New(slice.Type().Elem())
+		err := dec.Decode(elem.Interface())
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+}
@@ -0,0 +1,204 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestCodecFor(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        api.Codec
+	}{
+		{"application/json", api.JSON},
+		{"application/json; charset=utf-8", api.JSON},
+		{"application/problem+json", api.JSON},
+		{"text/xml", api.XML},
+		{"application/atom+xml", api.XML},
+		{"application/x-www-form-urlencoded", api.Form},
+		{"application/x-ndjson", api.NDJSON},
+		{"text/plain", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got, ok := api.CodecFor(tt.contentType)
+		if ok != (tt.want != nil) || got != tt.want {
+			t.Errorf("%s: want %T got %T", tt.contentType, tt.want, got)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	h := http.Header{}
+	h.Set("Accept", "text/plain, application/json;q=0.5, application/xml;q=0.9")
+
+	if got := api.Negotiate(h, api.NDJSON); got != api.XML {
+		t.Errorf("accept: want %T got %T", api.XML, got)
+	}
+
+	h.Set("Content-Type", "application/x-www-form-urlencoded")
+	if got := api.Negotiate(h, api.NDJSON); got != api.Form {
+		t.Errorf("content type: want %T got %T", api.Form, got)
+	}
+
+	if got := api.Negotiate(http.Header{}, api.NDJSON); got != api.NDJSON {
+		t.Errorf("fallback: want %T got %T", api.NDJSON, got)
+	}
+}
+
+func TestFormCodec(t *testing.T) {
+	type form struct {
+		Name    string   `form:"name"`
+		Count   int      `form:"count"`
+		Enabled bool     `form:"enabled"`
+		Tags    []string `form:"tag"`
+		Skip    string   `form:"-"`
+	}
+
+	in := form{Name: "a b", Count: 3, Enabled: true, Tags: []string{"x", "y"}, Skip: "no"}
+
+	buf := &bytes.Buffer{}
+	if err := api.Form.Encode(buf, in); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "count=3&enabled=true&name=a+b&tag=x&tag=y"
+	if buf.String() != want {
+		t.Errorf("encode: want '%s' got '%s'", want, buf)
+	}
+
+	var out form
+	if err := api.Form.Decode(bytes.NewReader(buf.Bytes()), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	in.Skip = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decode: want %+v got %+v", in, out)
+	}
+
+	var vals url.Values
+	if err := api.Form.Decode(bytes.NewReader(buf.Bytes()), &vals); err != nil {
+		t.Fatal(err)
+	}
+	if vals.Get("name") != "a b" {
+		t.Errorf("decode values: want 'a b' got '%s'", vals.Get("name"))
+	}
+}
+
+func TestNDJSONCodec(t *testing.T) {
+	in := []widget{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+
+	buf := &bytes.Buffer{}
+	if err := api.NDJSON.Encode(buf, in); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n"
+	if buf.String() != want {
+		t.Errorf("encode: want '%s' got '%s'", want, buf)
+	}
+
+	var out []widget
+	if err := api.NDJSON.Decode(buf, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decode: want %+v got %+v", in, out)
+	}
+}
+
+func TestDoCodecNegotiatesResponse(t *testing.T) {
+	type item struct {
+		ID   int    `xml:"id"`
+		Name string `xml:"name"`
+	}
+
+	mockDo := middleware.NewMock(func(req *http.Request) (*http.Response, error) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+			t.Errorf("content type: want 'application/x-www-form-urlencoded' got '%s'", ct)
+		}
+
+		res, err := middleware.MockResponse(func(req *http.Request) (int, string) {
+			return 200, `<item><id>7</id><name>sprocket</name></item>`
+		})(req)
+		if err != nil {
+			return nil, err
+		}
+		res.Header.Set("Content-Type", "application/xml")
+		return res, nil
+	})
+
+	c := api.NewClient("localhost", "/v1", 0, mockDo)
+
+	got, err := api.DoCodec[url.Values, item](context.Background(), c, api.Form, http.MethodPost, "/items", url.Values{"name": {"sprocket"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (item{ID: 7, Name: "sprocket"}); got != want {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+}
+
+func TestCodecHeadersKeepRequestHeaders(t *testing.T) {
+	var got http.Header
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Clone()
+		return &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"application/xml"}}, Body: http.NoBody, Request: req}, nil
+	})
+
+	c := api.New("http://localhost", api.WithTransport(transport), api.WithMiddleware(middleware.JSONHeaders))
+
+	// DoCodec sets the headers for its codec
+	type item struct {
+		Name string `xml:"name"`
+	}
+	api.DoCodec[item, item](context.Background(), c, api.XML, http.MethodPost, "/items", item{Name: "sprocket"})
+
+	if ct := got.Values("Content-Type"); len(ct) != 1 || ct[0] != "application/xml" {
+		t.Errorf("codec content type: want [application/xml] got %v", ct)
+	}
+	if accept := got.Values("Accept"); len(accept) != 1 || accept[0] != "application/xml" {
+		t.Errorf("codec accept: want [application/xml] got %v", accept)
+	}
+
+	// Multipart sets its own content type with the boundary
+	m := api.NewMultipart().Field("name", "sprocket")
+	req, err := m.NewRequest(context.Background(), c, http.MethodPost, "/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if ct := got.Values("Content-Type"); len(ct) != 1 || ct[0] != m.ContentType() {
+		t.Errorf("multipart content type: want [%s] got %v", m.ContentType(), ct)
+	}
+
+	// a request without a body has no content type
+	resp, err = c.Get(context.Background(), "/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if ct := got.Get("Content-Type"); ct != "" {
+		t.Errorf("get content type: want none got '%s'", ct)
+	}
+	if accept := got.Get("Accept"); accept != "application/json" {
+		t.Errorf("get accept: want 'application/json' got '%s'", accept)
+	}
+}
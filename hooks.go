@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestHook is called before a request is sent by the http.Client
+type RequestHook func(req *http.Request)
+
+// ResponseHook is called after a response is received by the http.Client
+type ResponseHook func(req *http.Request, resp *http.Response, elapsed time.Duration)
+
+// ErrorHook is called when the http.Client returns an error
+type ErrorHook func(req *http.Request, err error, elapsed time.Duration)
+
+// RetryHook is called by the retry middleware before a retry is made.
+// The attempt is the number of the retry starting at 1 and the delay
+// is how long the middleware will wait before making it.
+type RetryHook func(req *http.Request, attempt int, delay time.Duration)
+
+// hooks are the lifecycle hooks of a BaseClient
+type hooks struct {
+	request  []RequestHook
+	response []ResponseHook
+	err      []ErrorHook
+	retry    []RetryHook
+}
+
+// hooksKey is the context key for the hooks
+type hooksKey struct{}
+
+// OnRequest adds a hook that is called before each request is sent.
+// Hooks fire around each round trip, so a request that is retried
+// by middleware fires them once per attempt.
+func OnRequest(hook RequestHook) Option {
+	return func(cfg *config) {
+		cfg.hooks.request = append(cfg.hooks.request, hook)
+	}
+}
+
+// OnResponse adds a hook that is called after each response is received
+func OnResponse(hook ResponseHook) Option {
+	return func(cfg *config) {
+		cfg.hooks.response = append(cfg.hooks.response, hook)
+	}
+}
+
+// OnError adds a hook that is called when sending a request fails
+func OnError(hook ErrorHook) Option {
+	return func(cfg *config) {
+		cfg.hooks.err = append(cfg.hooks.err, hook)
+	}
+}
+
+// OnRetry adds a hook that is called by the retry middleware before each retry
+func OnRetry(hook RetryHook) Option {
+	return func(cfg *config) {
+		cfg.hooks.retry = append(cfg.hooks.retry, hook)
+	}
+}
+
+// NotifyRetry calls the retry hooks of the client that sent the request.
+// It is called by the retry middleware and is safe to call with a nil request.
+func NotifyRetry(req *http.Request, attempt int, delay time.Duration) {
+	if req == nil {
+		return
+	}
+
+	h, _ := req.Context().Value(hooksKey{}).(*hooks)
+	if h == nil {
+		return
+	}
+
+	for _, hook := range h.retry {
+		hook(req, attempt, delay)
+	}
+}
+
+// attach is a Do func that puts the hooks on the request's context
+// so middleware can reach them
+func (h *hooks) attach(next Dofn) Dofn {
+	if len(h.retry) == 0 {
+		return next
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		return next(req.WithContext(context.WithValue(req.Context(), hooksKey{}, h)))
+	}
+}
+
+// wrap is a Do func that fires the request, response and error hooks
+func (h *hooks) wrap(next Dofn) Dofn {
+	if len(h.request) == 0 && len(h.response) == 0 && len(h.err) == 0 {
+		return next
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		for _, hook := range h.request {
+			hook(req)
+		}
+
+		start := time.Now()
+		resp, err := next(req)
+		elapsed := time.Since(start)
+
+		if err != nil {
+			for _, hook := range h.err {
+				hook(req, err, elapsed)
+			}
+			return resp, err
+		}
+
+		for _, hook := range h.response {
+			hook(req, resp, elapsed)
+		}
+
+		return resp, err
+	}
+}
@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestHooks(t *testing.T) {
+	calls := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return &http.Response{StatusCode: 503, Body: http.NoBody, Request: req}, nil
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: req}, nil
+	})
+
+	requests, responses := 0, []int{}
+	retries := []int{}
+
+	c := api.New("http://localhost",
+		api.WithTransport(transport),
+		api.WithMiddleware(middleware.RetryOnStatusCodes(3, middleware.StatusCodeRange{Low: 500, High: 599})),
+		api.OnRequest(func(req *http.Request) {
+			requests++
+		}),
+		api.OnResponse(func(req *http.Request, resp *http.Response, elapsed time.Duration) {
+			responses = append(responses, resp.StatusCode)
+		}),
+		api.OnRetry(func(req *http.Request, attempt int, delay time.Duration) {
+			retries = append(retries, attempt)
+		}),
+	)
+
+	resp, err := c.Get(context.Background(), "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if requests != 2 {
+		t.Errorf("request hooks: want 2 got %d", requests)
+	}
+
+	if len(responses) != 2 || responses[0] != 503 || responses[1] != 200 {
+		t.Errorf("response hooks: want [503 200] got %v", responses)
+	}
+
+	if len(retries) != 1 || retries[0] != 1 {
+		t.Errorf("retry hooks: want [1] got %v", retries)
+	}
+}
+
+func TestOnError(t *testing.T) {
+	wantErr := errors.New("connection refused")
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, wantErr
+	})
+
+	var gotErr error
+	c := api.New("http://localhost",
+		api.WithTransport(transport),
+		api.OnError(func(req *http.Request, err error, elapsed time.Duration) {
+			gotErr = err
+		}),
+	)
+
+	_, err := c.Get(context.Background(), "/foo")
+	if !errors.Is(err, wantErr) || !errors.Is(gotErr, wantErr) {
+		t.Errorf("want %v got %v and hook %v", wantErr, err, gotErr)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+// DoJSON is DoCodec with the JSON codec.
+// It encodes body as JSON, sends it to the endpoint with the given method
+// through the client's middleware chain, and decodes the response in to Resp.
+func DoJSON[Req, Resp any](ctx context.Context, c Client, method, endpoint string, body Req) (Resp, error) {
+	return DoCodec[Req, Resp](ctx, c, JSON, method, endpoint, body)
+}
+
+// GetJSON is a convenience func for DoJSON with a GET and no request body
+func GetJSON[Resp any](ctx context.Context, c Client, endpoint string) (Resp, error) {
+	return DoJSON[any, Resp](ctx, c, http.MethodGet, endpoint, nil)
+}
+
+// PostJSON is a convenience func for DoJSON with a POST
+func PostJSON[Req, Resp any](ctx context.Context, c Client, endpoint string, body Req) (Resp, error) {
+	return DoJSON[Req, Resp](ctx, c, http.MethodPost, endpoint, body)
+}
+
+// PutJSON is a convenience func for DoJSON with a PUT
+func PutJSON[Req, Resp any](ctx context.Context, c Client, endpoint string, body Req) (Resp, error) {
+	return DoJSON[Req, Resp](ctx, c, http.MethodPut, endpoint, body)
+}
+
+// PatchJSON is a convenience func for DoJSON with a PATCH
+func PatchJSON[Req, Resp any](ctx context.Context, c Client, endpoint string, body Req) (Resp, error) {
+	return DoJSON[Req, Resp](ctx, c, http.MethodPatch, endpoint, body)
+}
+
+// DeleteJSON is a convenience func for DoJSON with a DELETE and no request body
+func DeleteJSON[Resp any](ctx context.Context, c Client, endpoint string) (Resp, error) {
+	return DoJSON[any, Resp](ctx, c, http.MethodDelete, endpoint, nil)
+}
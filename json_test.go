@@ -0,0 +1,76 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+type widget struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDoJSON(t *testing.T) {
+	ctx := context.Background()
+
+	mockDo := middleware.NewMockResponse(func(req *http.Request) (int, string) {
+		if req.Method != http.MethodPost {
+			t.Errorf("method: want '%s' got '%s'", http.MethodPost, req.Method)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("content type: want 'application/json' got '%s'", ct)
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		want := `{"id":0,"name":"sprocket"}`
+		if string(body) != want {
+			t.Errorf("request body: want '%s' got '%s'", want, body)
+		}
+
+		return 201, `{"id":7,"name":"sprocket"}`
+	})
+
+	c := api.NewClient("localhost", "/v1", 0, mockDo)
+
+	got, err := api.PostJSON[widget, widget](ctx, c, "/widgets", widget{Name: "sprocket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (widget{ID: 7, Name: "sprocket"}); got != want {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+}
+
+func TestDoJSONParseError(t *testing.T) {
+	ctx := context.Background()
+
+	mockDo := middleware.NewMockResponse(func(req *http.Request) (int, string) {
+		if req.Body != nil {
+			t.Error("expected no request body")
+		}
+		return 200, `not json`
+	})
+
+	c := api.NewClient("localhost", "/v1", 0, mockDo)
+
+	_, err := api.GetJSON[widget](ctx, c, "/widgets/7")
+
+	var pe api.ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+
+	if string(pe.Raw) != "not json" {
+		t.Errorf("raw: want 'not json' got '%s'", pe.Raw)
+	}
+}
@@ -0,0 +1,355 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// RateLimitState is the quota the server reported in its rate limit headers
+type RateLimitState struct {
+	Limit     int       // requests allowed in the window, 0 if not reported
+	Remaining int       // requests left in the window
+	Reset     time.Time // when the window resets, zero if not reported
+	Updated   time.Time // when the headers were last seen, zero if never
+}
+
+// AdaptiveRateLimiter is a Limiter that throttles requests using the quota the
+// server reports in X-RateLimit-*, RateLimit-* or RateLimit headers.
+//
+// When the remaining quota drops below Threshold of the limit, requests are
+// spread out evenly over the time left until the reset. When there are only
+// Reserve requests left they wait for the reset. Once the window has reset a
+// single request is let through to learn the new quota and the rest wait for
+// its response to be passed to Observe.
+//
+// When the server doesn't report a reset there is no window to pace over, so
+// the requests are only counted against the remaining quota and a 429 with a
+// Retry-After header is what makes them wait.
+type AdaptiveRateLimiter struct {
+	Threshold float64 // fraction of the limit left when pacing starts
+	Reserve   int     // requests to hold back for other clients of the quota
+
+	mu      sync.Mutex
+	state   RateLimitState
+	next    time.Time     // the earliest the next request can be sent when pacing
+	probing bool          // a request is out to learn the quota of the new window
+	changed chan struct{} // closed when the state changes
+}
+
+// NewAdaptiveRateLimiter creates an AdaptiveRateLimiter that starts pacing
+// at 20% of the quota left.
+func NewAdaptiveRateLimiter() *AdaptiveRateLimiter {
+	return &AdaptiveRateLimiter{Threshold: 0.2}
+}
+
+// State returns the last quota reported by the server, adjusted for the
+// requests sent since.
+func (a *AdaptiveRateLimiter) State() RateLimitState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.state
+}
+
+// Wait blocks until the request can be sent without going over the quota
+func (a *AdaptiveRateLimiter) Wait(ctx context.Context) error {
+	_, err := a.wait(ctx)
+	return err
+}
+
+// wait blocks until the request can be sent and reports if it is the probe of a new window
+func (a *AdaptiveRateLimiter) wait(ctx context.Context) (bool, error) {
+	for {
+		r := a.reserve(time.Now())
+		if r.ok && r.delay <= 0 {
+			return r.probe, nil
+		}
+
+		var timer *time.Timer
+		var fired <-chan time.Time
+		if r.delay > 0 {
+			timer = time.NewTimer(r.delay)
+			fired = timer.C
+		}
+
+		select {
+		case <-fired:
+			if r.ok {
+				return r.probe, nil
+			}
+		case <-r.changed:
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return false, ctx.Err()
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// reservation is the result of reserve
+type reservation struct {
+	ok      bool          // the request can be sent after the delay
+	probe   bool          // the request is the probe of a new window
+	delay   time.Duration // how long to wait
+	changed chan struct{} // when not ok, wait for the delay or a change of the state and try again
+}
+
+// reserve counts a request against the quota and returns how long to wait before sending it
+func (a *AdaptiveRateLimiter) reserve(now time.Time) reservation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// nothing is known about the quota
+	if a.state.Updated.IsZero() {
+		return reservation{ok: true}
+	}
+
+	// without a reset there is no window to pace over
+	if a.state.Reset.IsZero() {
+		if a.state.Remaining > 0 {
+			a.state.Remaining--
+		}
+		return reservation{ok: true}
+	}
+
+	// the window has reset so send one request to learn the new quota
+	if !now.Before(a.state.Reset) {
+		if a.probing {
+			return reservation{changed: a.changedChan()}
+		}
+		a.probing = true
+		return reservation{ok: true, probe: true}
+	}
+
+	untilReset := a.state.Reset.Sub(now)
+	remaining := a.state.Remaining
+
+	available := remaining - a.Reserve
+	if available <= 0 {
+		return reservation{delay: untilReset, changed: a.changedChan()}
+	}
+	a.state.Remaining--
+
+	// without a limit there is no threshold so only wait once the quota is used up
+	if a.state.Limit <= 0 || float64(remaining) > float64(a.state.Limit)*a.Threshold {
+		return reservation{ok: true}
+	}
+
+	// spread what is left over the rest of the window
+	interval := untilReset / time.Duration(available)
+	if a.next.Before(now) {
+		a.next = now
+	}
+	at := a.next
+	a.next = a.next.Add(interval)
+
+	return reservation{ok: true, delay: at.Sub(now)}
+}
+
+// changedChan returns the channel closed on the next change of the state.
+// It must be called with the lock held.
+func (a *AdaptiveRateLimiter) changedChan() chan struct{} {
+	if a.changed == nil {
+		a.changed = make(chan struct{})
+	}
+	return a.changed
+}
+
+// notify wakes the waiters to check the state again.
+// It must be called with the lock held.
+func (a *AdaptiveRateLimiter) notify() {
+	if a.changed != nil {
+		close(a.changed)
+		a.changed = nil
+	}
+}
+
+// settle forgets the quota of the old window when the probe's response
+// didn't report the new one, so the requests aren't held to one at a time
+func (a *AdaptiveRateLimiter) settle(probe bool) {
+	if !probe {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.probing {
+		a.state = RateLimitState{Limit: a.state.Limit}
+		a.probing = false
+		a.notify()
+	}
+}
+
+// Observe updates the quota from the rate limit headers of the response.
+// A 429 with a Retry-After header empties the quota until then.
+func (a *AdaptiveRateLimiter) Observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	now := time.Now()
+	state, ok := parseRateLimit(resp.Header, now)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if wait, found := parseRetryAfter(resp.Header.Get("Retry-After"), now); found {
+			state.Remaining = 0
+			state.Reset = now.Add(wait)
+			ok = true
+		}
+	}
+
+	if !ok {
+		return
+	}
+
+	state.Updated = now
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if state.Limit == 0 {
+		state.Limit = a.state.Limit
+	}
+	a.state = state
+	a.probing = false
+	a.notify()
+}
+
+// AdaptiveRateLimit is a Do func middleware that waits on the limiter before
+// each request and updates it from the rate limit headers of each response.
+func AdaptiveRateLimit(a *AdaptiveRateLimiter) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			probe, err := a.wait(req.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next(req)
+			a.Observe(resp)
+			a.settle(probe)
+
+			return resp, err
+		}
+
+	}
+}
+
+// parseRateLimit reads the quota from the headers.
+// It understands the IETF RateLimit header in both its "limit=, remaining=, reset="
+// and structured "r=;t=" forms, the RateLimit-* headers and the X-RateLimit-* headers.
+func parseRateLimit(h http.Header, now time.Time) (RateLimitState, bool) {
+	if v := h.Get("RateLimit"); v != "" {
+		params := rateLimitParams(v)
+		policy := rateLimitParams(h.Get("RateLimit-Policy"))
+
+		remaining, ok := firstInt(params, "remaining", "r")
+		if ok {
+			limit, _ := firstInt(params, "limit")
+			if limit == 0 {
+				limit, _ = firstInt(policy, "q")
+			}
+
+			state := RateLimitState{Limit: limit, Remaining: remaining}
+			if reset, found := firstInt(params, "reset", "t"); found {
+				state.Reset = now.Add(time.Duration(reset) * time.Second)
+			}
+
+			return state, true
+		}
+	}
+
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		remaining, err := strconv.Atoi(strings.TrimSpace(h.Get(prefix + "Remaining")))
+		if err != nil {
+			continue
+		}
+
+		limit, _ := strconv.Atoi(strings.TrimSpace(h.Get(prefix + "Limit")))
+
+		state := RateLimitState{Limit: limit, Remaining: remaining}
+		if reset, err := strconv.ParseInt(strings.TrimSpace(h.Get(prefix+"Reset")), 10, 64); err == nil {
+			state.Reset = resetTime(reset, now)
+		}
+
+		return state, true
+	}
+
+	return RateLimitState{}, false
+}
+
+// resetTime converts a reset value to a time.
+// Large values are unix timestamps, like GitHub sends, and the rest are
+// seconds from now.
+func resetTime(reset int64, now time.Time) time.Time {
+	if reset > 1e9 {
+		return time.Unix(reset, 0)
+	}
+
+	return now.Add(time.Duration(reset) * time.Second)
+}
+
+// rateLimitParams splits "a=1, b=2" or `"policy";a=1;b=2` in to a map
+func rateLimitParams(v string) map[string]string {
+	params := map[string]string{}
+
+	for _, part := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' }) {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(k)] = strings.Trim(val, `"`)
+		}
+	}
+
+	return params
+}
+
+// firstInt returns the first of the keys that has an int value
+func firstInt(params map[string]string, keys ...string) (int, bool) {
+	for _, k := range keys {
+		if n, err := strconv.Atoi(params[k]); err == nil {
+			return n, true
+		}
+	}
+
+	return 0, false
+}
+
+// parseRetryAfter parses a Retry-After value in seconds or as an HTTP date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		wait := t.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestAdaptiveRateLimiterObserve(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        http.Header
+		wantLimit     int
+		wantRemaining int
+		wantReset     time.Duration
+	}{
+		{
+			name:          "x-ratelimit delta",
+			header:        http.Header{"X-Ratelimit-Limit": {"100"}, "X-Ratelimit-Remaining": {"40"}, "X-Ratelimit-Reset": {"30"}},
+			wantLimit:     100,
+			wantRemaining: 40,
+			wantReset:     30 * time.Second,
+		},
+		{
+			name:          "x-ratelimit unix timestamp",
+			header:        http.Header{"X-Ratelimit-Limit": {"5000"}, "X-Ratelimit-Remaining": {"4999"}, "X-Ratelimit-Reset": {"4102444800"}},
+			wantLimit:     5000,
+			wantRemaining: 4999,
+			wantReset:     time.Until(time.Unix(4102444800, 0)),
+		},
+		{
+			name:          "ietf fields",
+			header:        http.Header{"Ratelimit-Limit": {"10"}, "Ratelimit-Remaining": {"2"}, "Ratelimit-Reset": {"5"}},
+			wantLimit:     10,
+			wantRemaining: 2,
+			wantReset:     5 * time.Second,
+		},
+		{
+			name:          "ietf combined",
+			header:        http.Header{"Ratelimit": {"limit=10, remaining=3, reset=7"}},
+			wantLimit:     10,
+			wantRemaining: 3,
+			wantReset:     7 * time.Second,
+		},
+		{
+			name:          "ietf structured",
+			header:        http.Header{"Ratelimit": {`"default";r=4;t=9`}, "Ratelimit-Policy": {`"default";q=20;w=60`}},
+			wantLimit:     20,
+			wantRemaining: 4,
+			wantReset:     9 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := middleware.NewAdaptiveRateLimiter()
+			a.Observe(&http.Response{StatusCode: 200, Header: tt.header})
+
+			state := a.State()
+			if state.Limit != tt.wantLimit || state.Remaining != tt.wantRemaining {
+				t.Errorf("want %d/%d got %d/%d", tt.wantRemaining, tt.wantLimit, state.Remaining, state.Limit)
+			}
+
+			if diff := time.Until(state.Reset) - tt.wantReset; diff > time.Second || diff < -time.Second {
+				t.Errorf("reset: want about %v got %v", tt.wantReset, time.Until(state.Reset))
+			}
+		})
+	}
+}
+
+func TestAdaptiveRateLimitWaitsForReset(t *testing.T) {
+	a := middleware.NewAdaptiveRateLimiter()
+
+	calls := 0
+	do := middleware.AdaptiveRateLimit(a)(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Ratelimit": {"limit=10, remaining=0, reset=1"}},
+		}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	if _, err := do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	// the quota is used up so the next request has to wait for the reset
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+	if _, err := do(req); err == nil {
+		t.Error("expected the request to wait for the reset")
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestAdaptiveRateLimitRetryAfter(t *testing.T) {
+	a := middleware.NewAdaptiveRateLimiter()
+	a.Observe(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"2"}},
+	})
+
+	state := a.State()
+	if state.Remaining != 0 || time.Until(state.Reset) < time.Second {
+		t.Errorf("expected the quota to be empty for 2s, got %+v", state)
+	}
+}
+
+func TestAdaptiveRateLimitPaces(t *testing.T) {
+	a := middleware.NewAdaptiveRateLimiter()
+	a.Observe(&http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Ratelimit": {"limit=100, remaining=5, reset=1"}},
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := a.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 5 left over 1s is a request every 200ms or so
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("expected the requests to be spread out, took %v", elapsed)
+	}
+}
+
+func TestAdaptiveRateLimitNoBurstAtReset(t *testing.T) {
+	a := middleware.NewAdaptiveRateLimiter()
+	a.Observe(&http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Ratelimit": {"limit=10, remaining=0, reset=1"}},
+	})
+	reset := time.Now().Add(time.Second)
+
+	// the server allows 10 requests in the window after the reset
+	var mu sync.Mutex
+	calls, rejected, remaining := 0, 0, 10
+	do := middleware.AdaptiveRateLimit(a)(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		if time.Now().Before(reset.Add(-50*time.Millisecond)) || remaining == 0 {
+			rejected++
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}, nil
+		}
+		remaining--
+
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Ratelimit": {fmt.Sprintf("limit=10, remaining=%d, reset=60", remaining)}},
+		}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			do(req)
+		}()
+	}
+	wg.Wait()
+
+	if rejected != 0 {
+		t.Errorf("expected no requests over the quota, got %d of %d", rejected, calls)
+	}
+
+	if calls == 0 {
+		t.Errorf("expected the requests to go after the reset")
+	}
+}
+
+func TestAdaptiveRateLimitWithoutReset(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"x-ratelimit", http.Header{"X-Ratelimit-Limit": {"5000"}, "X-Ratelimit-Remaining": {"4999"}}},
+		{"ietf", http.Header{"Ratelimit": {"limit=5000, remaining=4999"}}},
+		{"probe without headers", http.Header{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := middleware.NewAdaptiveRateLimiter()
+			if len(tt.header) == 0 {
+				// a window that has already reset
+				a.Observe(&http.Response{StatusCode: 200, Header: http.Header{"Ratelimit": {"limit=5000, remaining=0, reset=0"}}})
+			}
+
+			var mu sync.Mutex
+			inFlight, maxInFlight := 0, 0
+			do := middleware.AdaptiveRateLimit(a)(func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				inFlight++
+				if inFlight > maxInFlight {
+					maxInFlight = inFlight
+				}
+				mu.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+
+				return &http.Response{StatusCode: 200, Header: tt.header}, nil
+			})
+
+			// the first request learns the quota
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+			do(req)
+
+			wg := sync.WaitGroup{}
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+					do(req)
+				}()
+			}
+			wg.Wait()
+
+			if maxInFlight < 2 {
+				t.Errorf("expected the requests to run concurrently, got at most %d at a time", maxInFlight)
+			}
+		})
+	}
+}
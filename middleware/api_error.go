@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Reisender/go-api"
+)
+
+// ErrorStatusCodes is the status code range representing 4XX and 5XX status codes
+var ErrorStatusCodes = StatusCodeRange{Low: 400, High: 599}
+
+// Problem is an RFC 7807 problem details document.
+// Members that aren't part of the RFC are kept in Extensions.
+type Problem struct {
+	Type       string                     `json:"type,omitempty"`
+	Title      string                     `json:"title,omitempty"`
+	Status     int                        `json:"status,omitempty"`
+	Detail     string                     `json:"detail,omitempty"`
+	Instance   string                     `json:"instance,omitempty"`
+	Extensions map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the problem and collects the extension members
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	type problem Problem // avoid recursing back in to UnmarshalJSON
+	err := json.Unmarshal(b, (*problem)(p))
+	if err != nil {
+		return err
+	}
+
+	members := map[string]json.RawMessage{}
+	err = json.Unmarshal(b, &members)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, name)
+	}
+
+	p.Extensions = nil
+	if len(members) > 0 {
+		p.Extensions = members
+	}
+
+	return nil
+}
+
+// APIError is the error for a response with an error status code.
+// It keeps the response details that ErrStatusCode drops and unwraps
+// to an ErrStatusCode so errors.As works for either type.
+type APIError struct {
+	Status  string
+	Code    int
+	Header  http.Header
+	Body    []byte   // the raw response body
+	Problem *Problem // nil when the body couldn't be decoded
+}
+
+// Error implements the error interface
+func (e APIError) Error() string {
+	parts := []string{e.Status}
+	if e.Status == "" {
+		parts[0] = strconv.Itoa(e.Code)
+	}
+
+	if e.Problem != nil {
+		if e.Problem.Title != "" {
+			parts = append(parts, e.Problem.Title)
+		}
+		if e.Problem.Detail != "" {
+			parts = append(parts, e.Problem.Detail)
+		}
+	}
+
+	return strings.Join(parts, ": ")
+}
+
+// Unwrap returns the ErrStatusCode for the response
+func (e APIError) Unwrap() error {
+	return ErrStatusCode{e.Status, e.Code}
+}
+
+// APIErrorOnStatusCodes returns an APIError for responses with the status codes.
+// With no ranges it defaults to the ErrorStatusCodes range.
+// The body is decoded when it is an application/problem+json document or JSON
+// in a common error shape like {"error": "..."} or {"message": "..."}.
+// The response body is replaced so it can still be read by the caller.
+func APIErrorOnStatusCodes(statusCodes ...StatusCodeRange) api.Middleware {
+	// default to the ErrorStatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = []StatusCodeRange{ErrorStatusCodes}
+	}
+
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			// pass the request on to the next
+			res, err := next(req)
+
+			// make sure there isn't already an error
+			if err != nil {
+				return res, err
+			}
+
+			// the request asked for the status codes to be left alone
+			if requestOptions(req).SkipStatusErrors {
+				return res, err
+			}
+
+			if !InRanges(res.StatusCode, statusCodes) {
+				return res, err
+			}
+
+			apiErr := APIError{
+				Status: res.Status,
+				Code:   res.StatusCode,
+				Header: res.Header,
+			}
+
+			if res.Body != nil {
+				apiErr.Body, err = io.ReadAll(res.Body)
+				res.Body.Close()
+				res.Body = io.NopCloser(bytes.NewReader(apiErr.Body))
+				if err != nil {
+					return res, err
+				}
+			}
+
+			apiErr.Problem = decodeProblem(res.Header.Get("Content-Type"), apiErr.Body)
+			if apiErr.Problem != nil && apiErr.Problem.Status == 0 {
+				apiErr.Problem.Status = res.StatusCode
+			}
+
+			return res, apiErr
+		}
+
+	}
+}
+
+// decodeProblem decodes the body in to a Problem.
+// It returns nil if the body isn't JSON or isn't a known error shape.
+func decodeProblem(contentType string, body []byte) *Problem {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		// some APIs send JSON errors without a Content-Type
+		if mediaType != "" || !json.Valid(body) {
+			return nil
+		}
+	}
+
+	p := &Problem{}
+	err := json.Unmarshal(body, p)
+	if err != nil {
+		return nil
+	}
+
+	if mediaType == "application/problem+json" || p.Type != "" || p.Title != "" || p.Detail != "" {
+		return p
+	}
+
+	// look for the common {"error": ...} shapes
+	shape := struct {
+		Error            json.RawMessage `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+		Message          string          `json:"message"`
+		Errors           []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	json.Unmarshal(body, &shape)
+
+	var errStr string
+	errObj := struct {
+		Code    json.RawMessage `json:"code"`
+		Type    string          `json:"type"`
+		Message string          `json:"message"`
+	}{}
+
+	switch {
+	case json.Unmarshal(shape.Error, &errStr) == nil && errStr != "":
+		// {"error": "invalid_grant", "error_description": "..."}
+		p.Title = errStr
+		p.Detail = shape.ErrorDescription
+	case json.Unmarshal(shape.Error, &errObj) == nil && (errObj.Message != "" || errObj.Type != ""):
+		// {"error": {"type": "...", "message": "..."}}
+		p.Type = errObj.Type
+		p.Detail = errObj.Message
+		if p.Type == "" && len(errObj.Code) > 0 {
+			p.Title = strings.Trim(string(errObj.Code), `"`)
+		}
+	case shape.Message != "":
+		// {"message": "..."}
+		p.Detail = shape.Message
+	case len(shape.Errors) > 0 && shape.Errors[0].Message != "":
+		// {"errors": [{"message": "..."}]}
+		p.Detail = shape.Errors[0].Message
+	default:
+		return nil
+	}
+
+	return p
+}
@@ -0,0 +1,129 @@
+package middleware_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestAPIErrorOnStatusCodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     string
+		wantTitle   string
+		wantDetail  string
+	}{
+		{
+			name:        "problem json",
+			contentType: "application/problem+json",
+			body:        `{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","balance":30}`,
+			wantErr:     "403 Forbidden: You do not have enough credit.: Your current balance is 30, but that costs 50.",
+			wantTitle:   "You do not have enough credit.",
+			wantDetail:  "Your current balance is 30, but that costs 50.",
+		},
+		{
+			name:        "error string",
+			contentType: "application/json",
+			body:        `{"error":"invalid_grant","error_description":"token expired"}`,
+			wantErr:     "403 Forbidden: invalid_grant: token expired",
+			wantTitle:   "invalid_grant",
+			wantDetail:  "token expired",
+		},
+		{
+			name:        "error object",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"error":{"type":"card_error","message":"Your card was declined."}}`,
+			wantErr:     "403 Forbidden: Your card was declined.",
+			wantDetail:  "Your card was declined.",
+		},
+		{
+			name:       "message without content type",
+			body:       `{"message":"Not allowed"}`,
+			wantErr:    "403 Forbidden: Not allowed",
+			wantDetail: "Not allowed",
+		},
+		{
+			name:        "plain text",
+			contentType: "text/plain",
+			body:        `nope`,
+			wantErr:     "403 Forbidden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := middleware.APIErrorOnStatusCodes()
+			res, err := m(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "403 Forbidden",
+					StatusCode: 403,
+					Header:     http.Header{"Content-Type": []string{tt.contentType}},
+					Body:       io.NopCloser(strings.NewReader(tt.body)),
+				}, nil
+			})(nil)
+
+			var apiErr middleware.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an APIError, got %v", err)
+			}
+
+			var statusErr middleware.ErrStatusCode
+			if !errors.As(err, &statusErr) || statusErr.Code != 403 {
+				t.Errorf("expected an ErrStatusCode with 403, got %v", statusErr)
+			}
+
+			if err.Error() != tt.wantErr {
+				t.Errorf("error: want '%s' got '%s'", tt.wantErr, err)
+			}
+
+			if string(apiErr.Body) != tt.body {
+				t.Errorf("body: want '%s' got '%s'", tt.body, apiErr.Body)
+			}
+
+			if tt.wantTitle != "" || tt.wantDetail != "" {
+				if apiErr.Problem == nil {
+					t.Fatal("expected a decoded problem")
+				}
+				if apiErr.Problem.Title != tt.wantTitle || apiErr.Problem.Detail != tt.wantDetail {
+					t.Errorf("problem: want '%s' '%s' got '%s' '%s'", tt.wantTitle, tt.wantDetail, apiErr.Problem.Title, apiErr.Problem.Detail)
+				}
+			}
+
+			// the body should still be readable
+			body, _ := io.ReadAll(res.Body)
+			if string(body) != tt.body {
+				t.Errorf("response body: want '%s' got '%s'", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestProblemExtensions(t *testing.T) {
+	m := middleware.APIErrorOnStatusCodes()
+	_, err := m(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 400,
+			Header:     http.Header{"Content-Type": []string{"application/problem+json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"title":"Bad","balance":30}`)),
+		}, nil
+	})(nil)
+
+	var apiErr middleware.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+
+	if got := string(apiErr.Problem.Extensions["balance"]); got != "30" {
+		t.Errorf("extension: want '30' got '%s'", got)
+	}
+
+	if apiErr.Problem.Status != 400 {
+		t.Errorf("status: want 400 got %d", apiErr.Problem.Status)
+	}
+}
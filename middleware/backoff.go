@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff computes the delay before a retry.
+// The retry is the number of the retry starting at 0 and prev is the
+// delay used before the last retry, 0 for the first one.
+type Backoff interface {
+	Delay(retry uint, prev time.Duration) time.Duration
+}
+
+// BackoffFunc allows a func to be used as a Backoff
+type BackoffFunc func(retry uint, prev time.Duration) time.Duration
+
+// Delay implements the Backoff interface
+func (f BackoffFunc) Delay(retry uint, prev time.Duration) time.Duration {
+	return f(retry, prev)
+}
+
+// Rand is the random source for the jitter backoffs
+type Rand interface {
+	Int63n(n int64) int64
+}
+
+// NewRand creates a Rand from the seed that is safe for concurrent use.
+// A fixed seed makes the jitter repeatable for tests.
+func NewRand(seed int64) Rand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (lr *lockedRand) Int63n(n int64) int64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return lr.r.Int63n(n)
+}
+
+// globalRand uses the math/rand top level funcs
+type globalRand struct{}
+
+func (globalRand) Int63n(n int64) int64 {
+	return rand.Int63n(n)
+}
+
+// between returns a random duration in [low, high]
+func between(rnd Rand, low, high time.Duration) time.Duration {
+	if rnd == nil {
+		rnd = globalRand{}
+	}
+
+	if high <= low {
+		return low
+	}
+
+	return low + time.Duration(rnd.Int63n(int64(high-low)+1))
+}
+
+// exponential is base * factor^retry capped at max
+func exponential(base, max time.Duration, factor float64, retry uint) time.Duration {
+	delay := float64(base) * math.Pow(factor, float64(retry))
+	if delay > float64(max) || delay < 0 {
+		return max
+	}
+
+	return time.Duration(delay)
+}
+
+// ConstantBackoff waits the same delay before every retry
+func ConstantBackoff(delay time.Duration) Backoff {
+	return BackoffFunc(func(retry uint, prev time.Duration) time.Duration {
+		return delay
+	})
+}
+
+// LinearBackoff waits base and then step longer for each retry up to max
+func LinearBackoff(base, step, max time.Duration) Backoff {
+	return BackoffFunc(func(retry uint, prev time.Duration) time.Duration {
+		delay := base + step*time.Duration(retry)
+		if delay > max || delay < 0 {
+			return max
+		}
+		return delay
+	})
+}
+
+// ExponentialBackoff waits base and then factor times longer for each retry up to max
+func ExponentialBackoff(base, max time.Duration, factor float64) Backoff {
+	return BackoffFunc(func(retry uint, prev time.Duration) time.Duration {
+		return exponential(base, max, factor, retry)
+	})
+}
+
+// FullJitterBackoff waits a random delay between 0 and the doubling
+// exponential backoff. A nil rnd uses the math/rand funcs.
+func FullJitterBackoff(base, max time.Duration, rnd Rand) Backoff {
+	return BackoffFunc(func(retry uint, prev time.Duration) time.Duration {
+		return between(rnd, 0, exponential(base, max, 2, retry))
+	})
+}
+
+// EqualJitterBackoff waits half of the doubling exponential backoff plus a
+// random delay up to the other half. A nil rnd uses the math/rand funcs.
+func EqualJitterBackoff(base, max time.Duration, rnd Rand) Backoff {
+	return BackoffFunc(func(retry uint, prev time.Duration) time.Duration {
+		half := exponential(base, max, 2, retry) / 2
+		return half + between(rnd, 0, half)
+	})
+}
+
+// DecorrelatedJitterBackoff waits a random delay between base and three
+// times the previous delay, capped at max. A nil rnd uses the math/rand funcs.
+func DecorrelatedJitterBackoff(base, max time.Duration, rnd Rand) Backoff {
+	return BackoffFunc(func(retry uint, prev time.Duration) time.Duration {
+		if prev < base {
+			prev = base
+		}
+
+		delay := between(rnd, base, prev*3)
+		if delay > max {
+			return max
+		}
+		return delay
+	})
+}
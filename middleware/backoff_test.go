@@ -0,0 +1,152 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	base := 10 * time.Millisecond
+	max := 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		backoff middleware.Backoff
+		min     func(retry uint) time.Duration
+		max     func(retry uint) time.Duration
+	}{
+		{
+			"constant",
+			middleware.ConstantBackoff(base),
+			func(uint) time.Duration { return base },
+			func(uint) time.Duration { return base },
+		},
+		{
+			"linear",
+			middleware.LinearBackoff(base, base, max),
+			func(r uint) time.Duration { return minDuration(base*time.Duration(r+1), max) },
+			func(r uint) time.Duration { return minDuration(base*time.Duration(r+1), max) },
+		},
+		{
+			"exponential",
+			middleware.ExponentialBackoff(base, max, 2),
+			func(r uint) time.Duration { return minDuration(base<<r, max) },
+			func(r uint) time.Duration { return minDuration(base<<r, max) },
+		},
+		{
+			"full jitter",
+			middleware.FullJitterBackoff(base, max, middleware.NewRand(1)),
+			func(uint) time.Duration { return 0 },
+			func(r uint) time.Duration { return minDuration(base<<r, max) },
+		},
+		{
+			"equal jitter",
+			middleware.EqualJitterBackoff(base, max, middleware.NewRand(1)),
+			func(r uint) time.Duration { return minDuration(base<<r, max) / 2 },
+			func(r uint) time.Duration { return minDuration(base<<r, max) },
+		},
+		{
+			"decorrelated jitter",
+			middleware.DecorrelatedJitterBackoff(base, max, middleware.NewRand(1)),
+			func(uint) time.Duration { return base },
+			func(uint) time.Duration { return max },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := time.Duration(0)
+			for retry := uint(0); retry < 10; retry++ {
+				delay := tt.backoff.Delay(retry, prev)
+				if delay < tt.min(retry) || delay > tt.max(retry) {
+					t.Errorf("retry %d: want between %v and %v got %v", retry, tt.min(retry), tt.max(retry), delay)
+				}
+				prev = delay
+			}
+		})
+	}
+}
+
+func TestBackoffSeeded(t *testing.T) {
+	delays := func() []time.Duration {
+		b := middleware.DecorrelatedJitterBackoff(time.Millisecond, time.Second, middleware.NewRand(42))
+		out := []time.Duration{}
+		prev := time.Duration(0)
+		for retry := uint(0); retry < 5; retry++ {
+			prev = b.Delay(retry, prev)
+			out = append(out, prev)
+		}
+		return out
+	}
+
+	first, second := delays(), delays()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("retry %d: want %v got %v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "", nil)
+
+	tries := 0
+	retries := []uint{}
+	backoff := middleware.BackoffFunc(func(retry uint, prev time.Duration) time.Duration {
+		retries = append(retries, retry)
+		return time.Millisecond
+	})
+
+	_, err := middleware.RetryWithBackoff(3, backoff)(func(req *http.Request) (*http.Response, error) {
+		tries++
+		return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+	})(req)
+
+	if tries != 4 {
+		t.Errorf("tries: want 4 got %d", tries)
+	}
+
+	if len(retries) != 3 || retries[0] != 0 || retries[2] != 2 {
+		t.Errorf("retries: want [0 1 2] got %v", retries)
+	}
+
+	var maxErr middleware.ErrMaxRetries
+	if !errors.As(err, &maxErr) {
+		t.Errorf("expected ErrMaxRetries, got %v", err)
+	}
+}
+
+func TestRetryPolicyBackoffRetryAfter(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "", nil)
+
+	tries := 0
+	start := time.Now()
+	middleware.RetryWithPolicies(middleware.RetryPolicy{
+		Ranges:     []middleware.StatusCodeRange{{Low: 429, High: 429}},
+		MaxRetries: 1,
+		Backoff:    middleware.ConstantBackoff(time.Millisecond),
+	})(func(req *http.Request) (*http.Response, error) {
+		tries++
+		return &http.Response{StatusCode: 429, Header: http.Header{"Retry-After": {"1"}}, Body: http.NoBody}, nil
+	})(req)
+
+	if tries != 2 {
+		t.Errorf("tries: want 2 got %d", tries)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected the Retry-After of 1s to be honored, took %v", elapsed)
+	}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// ErrBulkheadFull is returned when a request can't wait because the queue of the bulkhead is full
+var ErrBulkheadFull = errors.New("bulkhead queue is full")
+
+// BulkheadStats are the numbers for a Bulkhead
+type BulkheadStats struct {
+	InFlight int           // requests holding a slot
+	Queued   int           // requests waiting for a slot
+	Rejected int64         // requests turned away because the queue was full
+	Waited   int64         // requests that had to wait for a slot
+	WaitTime time.Duration // total time spent waiting for a slot
+	MaxWait  time.Duration // longest time spent waiting for a slot
+}
+
+// Bulkhead caps the number of requests in flight. Requests over the cap wait
+// in order for a slot, up to maxQueue of them if it is more than 0.
+type Bulkhead struct {
+	slots    chan struct{}
+	maxQueue int
+
+	mu    sync.Mutex
+	stats BulkheadStats
+}
+
+// NewBulkhead creates a Bulkhead with max slots and a queue of up to
+// maxQueue waiters, or no limit on the waiters if maxQueue is 0
+func NewBulkhead(max, maxQueue int) *Bulkhead {
+	if max < 1 {
+		max = 1
+	}
+
+	return &Bulkhead{
+		slots:    make(chan struct{}, max),
+		maxQueue: maxQueue,
+	}
+}
+
+// Stats returns the current numbers for the bulkhead
+func (b *Bulkhead) Stats() BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	stats.InFlight = len(b.slots)
+
+	return stats
+}
+
+// Acquire takes a slot, waiting for one if they are all taken.
+// It returns ErrBulkheadFull if the queue is full or the context's
+// error if it is done first.
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	b.mu.Lock()
+	if b.maxQueue > 0 && b.stats.Queued >= b.maxQueue {
+		b.stats.Rejected++
+		b.mu.Unlock()
+		return ErrBulkheadFull
+	}
+	b.stats.Queued++
+	b.mu.Unlock()
+
+	start := time.Now()
+	var err error
+	select {
+	case b.slots <- struct{}{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	wait := time.Since(start)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Queued--
+	b.stats.Waited++
+	b.stats.WaitTime += wait
+	if wait > b.stats.MaxWait {
+		b.stats.MaxWait = wait
+	}
+
+	return err
+}
+
+// Release gives back a slot taken by Acquire
+func (b *Bulkhead) Release() {
+	<-b.slots
+}
+
+// BulkheadLimit is a Do func middleware that holds a slot of the bulkhead
+// for each request until its response body is closed
+func BulkheadLimit(b *Bulkhead) api.Middleware {
+	return BulkheadBy(func(*http.Request) string { return "" }, func(string) *Bulkhead { return b })
+}
+
+// BulkheadBy is a Do func middleware that keeps a bulkhead for each key of
+// the requests. The bulkhead for a key is created with newBulkhead the first
+// time the key is seen. The slot is held until the response body is closed.
+func BulkheadBy(key func(req *http.Request) string, newBulkhead func(key string) *Bulkhead) api.Middleware {
+	bulkheads := newKeyed(newBulkhead)
+
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			b := bulkheads.get(key(req))
+
+			err := b.Acquire(req.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				b.Release()
+				return resp, err
+			}
+
+			// hold the slot until the body is read
+			api.CancelOnClose(resp, b.Release)
+
+			return resp, nil
+		}
+
+	}
+}
+
+// BulkheadPerHost is a Do func middleware that caps each host separately
+func BulkheadPerHost(max, maxQueue int) api.Middleware {
+	return BulkheadBy(requestHost, func(string) *Bulkhead {
+		return NewBulkhead(max, maxQueue)
+	})
+}
+
+// bulkheadPartitionKey is the context key for the bulkhead partition
+type bulkheadPartitionKey struct{}
+
+// ContextWithBulkheadPartition returns a copy of the context with the name
+// of the partition for BulkheadPerPartition, like "batch" or "interactive"
+func ContextWithBulkheadPartition(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, bulkheadPartitionKey{}, name)
+}
+
+// BulkheadPerPartition is a Do func middleware that caps each named partition
+// separately so a batch job can't starve the interactive requests. The
+// requests without a partition on their context use the "" partition.
+// Partitions missing from the map are not limited.
+func BulkheadPerPartition(partitions map[string]*Bulkhead) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+		limited := map[string]api.Dofn{}
+		for name, b := range partitions {
+			limited[name] = BulkheadLimit(b)(next)
+		}
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			name, _ := req.Context().Value(bulkheadPartitionKey{}).(string)
+
+			do, ok := limited[name]
+			if !ok {
+				return next(req)
+			}
+
+			return do(req)
+		}
+
+	}
+}
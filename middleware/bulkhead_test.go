@@ -0,0 +1,116 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestBulkhead(t *testing.T) {
+	b := middleware.NewBulkhead(2, 1)
+
+	release := make(chan struct{})
+	do := middleware.BulkheadLimit(b)(func(req *http.Request) (*http.Response, error) {
+		<-release
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+
+	// fill the 2 slots and the queue of 1
+	responses := make(chan *http.Response, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+			resp, _ := do(req)
+			responses <- resp
+		}()
+	}
+
+	for b.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if b.Stats().InFlight != 2 {
+		t.Errorf("in flight: want 2 got %d", b.Stats().InFlight)
+	}
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+	_, err := do(req)
+	if !errors.Is(err, middleware.ErrBulkheadFull) {
+		t.Errorf("expected ErrBulkheadFull, got %v", err)
+	}
+
+	// the slots are held until the bodies are closed
+	close(release)
+	resp := <-responses
+	<-responses
+	if b.Stats().Queued != 1 {
+		t.Errorf("queued: want 1 got %d", b.Stats().Queued)
+	}
+
+	resp.Body.Close()
+	(<-responses).Body.Close()
+
+	stats := b.Stats()
+	if stats.Waited != 1 || stats.Rejected != 1 || stats.WaitTime <= 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBulkheadContextCanceled(t *testing.T) {
+	b := middleware.NewBulkhead(1, 0)
+	b.Acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := b.Acquire(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	if b.Stats().Queued != 0 {
+		t.Errorf("queued: want 0 got %d", b.Stats().Queued)
+	}
+}
+
+func TestBulkheadPerPartition(t *testing.T) {
+	batch := middleware.NewBulkhead(1, 0)
+	interactive := middleware.NewBulkhead(1, 0)
+
+	do := middleware.BulkheadPerPartition(map[string]*middleware.Bulkhead{
+		"batch": batch,
+		"":      interactive,
+	})(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+
+	// hold the batch slot
+	ctx := middleware.ContextWithBulkheadPartition(context.Background(), "batch")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/", nil)
+	held, _ := do(req)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+		resp, err := do(req)
+		if err != nil {
+			t.Errorf("expected the interactive request to go through, got %v", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+	wg.Wait()
+
+	if batch.Stats().InFlight != 1 || interactive.Stats().InFlight != 0 {
+		t.Errorf("want 1 batch and 0 interactive in flight got %d and %d", batch.Stats().InFlight, interactive.Stats().InFlight)
+	}
+
+	held.Body.Close()
+}
@@ -32,7 +32,10 @@ func Cache(mode Mode, ttl time.Duration, store GetSetter) api.Middleware {
 
 		// return a new Dofn
 		return func(req *http.Request) (*http.Response, error) {
-			// TOD: do the cache logic
+			// the request asked to go around the cache
+			if requestOptions(req).SkipCache {
+				return next(req)
+			}
 
 			key := getCacheKey(req)
 			headersKey := fmt.Sprintf("%s-headers", key)
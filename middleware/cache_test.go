@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/Reisender/go-api"
 )
 
 // MockCache implements the GetSetter interface for testing
@@ -197,3 +200,34 @@ func TestGetCacheKey(t *testing.T) {
 		t.Error("Expected same key for identical requests")
 	}
 }
+
+func TestCacheSkippedByRequestOptions(t *testing.T) {
+	store := NewMockCache()
+
+	calls := 0
+	handler := func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString("test response")),
+		}, nil
+	}
+
+	wrappedHandler := Cache(ModeCacheOnly, 1*time.Minute, store)(handler)
+
+	ctx := api.WithRequestOptions(context.Background(), api.SkipCache())
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+
+	resp, err := wrappedHandler(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.StatusCode != 200 || calls != 1 {
+		t.Errorf("Expected the handler to be called, got status %d and %d calls", resp.StatusCode, calls)
+	}
+
+	if len(store.cache) != 0 {
+		t.Error("Expected nothing to be stored in the cache")
+	}
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // requests are sent
+	CircuitOpen                         // requests fail right away
+	CircuitHalfOpen                     // a few probe requests are sent to see if the upstream is back
+)
+
+// String implements the fmt.Stringer interface
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// ErrCircuitOpen is the error returned without sending the request when the circuit is open
+type ErrCircuitOpen struct {
+	Until time.Time // when the circuit will let a probe request through
+}
+
+// Error implements the error interface
+func (e ErrCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit breaker is open until %s", e.Until.Format(time.RFC3339))
+}
+
+// ServerErrorStatusCodes is the status code range representing 5XX status codes
+var ServerErrorStatusCodes = StatusCodeRange{Low: 500, High: 599}
+
+// CircuitBreaker stops sending requests to an upstream that is failing.
+//
+// It opens after FailureThreshold failures in a row and fails the requests
+// with ErrCircuitOpen until the CoolDown has passed. Then it is half-open and
+// lets HalfOpenRequests probes through. SuccessThreshold successful probes in
+// a row close it again while a failed probe opens it for another CoolDown.
+//
+// Errors from next are failures, except for the request's context being
+// canceled, and so are the responses with status codes in the Ranges.
+// The zero values of the settings use the defaults.
+type CircuitBreaker struct {
+	Ranges           []StatusCodeRange // failing status codes, ServerErrorStatusCodes if empty
+	FailureThreshold int               // failures in a row to open, 5 if 0
+	SuccessThreshold int               // successful probes in a row to close, 1 if 0
+	CoolDown         time.Duration     // how long to stay open, 30s if 0
+	HalfOpenRequests int               // probes to let through at once when half-open, 1 if 0
+
+	// OnStateChange is called after the state changes, for alerting when the circuit opens
+	OnStateChange func(from, to CircuitState)
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	successes int
+	probes    int
+	openUntil time.Time
+}
+
+// State returns the current state of the circuit
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+// allow reports if a request can be sent and if it is a probe
+func (cb *CircuitBreaker) allow(now time.Time) (bool, error) {
+	cb.mu.Lock()
+	from := cb.state
+
+	if cb.state == CircuitOpen {
+		if now.Before(cb.openUntil) {
+			cb.mu.Unlock()
+			return false, ErrCircuitOpen{cb.openUntil}
+		}
+		cb.setState(CircuitHalfOpen)
+	}
+
+	probe := cb.state == CircuitHalfOpen
+	if probe {
+		if cb.probes >= defaultInt(cb.HalfOpenRequests, 1) {
+			cb.mu.Unlock()
+			cb.notify(from, CircuitHalfOpen)
+			return false, ErrCircuitOpen{cb.openUntil}
+		}
+		cb.probes++
+	}
+
+	to := cb.state
+	cb.mu.Unlock()
+	cb.notify(from, to)
+
+	return probe, nil
+}
+
+// record updates the circuit with the result of a request
+func (cb *CircuitBreaker) record(probe bool, failed, ignored bool, now time.Time) {
+	cb.mu.Lock()
+	from := cb.state
+
+	if probe {
+		cb.probes--
+	}
+
+	switch {
+	case ignored:
+	case failed && (probe || cb.state == CircuitHalfOpen):
+		cb.open(now)
+	case failed:
+		cb.failures++
+		if cb.state == CircuitClosed && cb.failures >= defaultInt(cb.FailureThreshold, 5) {
+			cb.open(now)
+		}
+	case cb.state == CircuitHalfOpen:
+		cb.successes++
+		if cb.successes >= defaultInt(cb.SuccessThreshold, 1) {
+			cb.setState(CircuitClosed)
+		}
+	default:
+		cb.failures = 0
+	}
+
+	to := cb.state
+	cb.mu.Unlock()
+	cb.notify(from, to)
+}
+
+// open opens the circuit for the cool down
+func (cb *CircuitBreaker) open(now time.Time) {
+	coolDown := cb.CoolDown
+	if coolDown == 0 {
+		coolDown = 30 * time.Second
+	}
+
+	cb.openUntil = now.Add(coolDown)
+	cb.setState(CircuitOpen)
+}
+
+// setState changes the state and resets the counts
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	cb.state = state
+	cb.failures = 0
+	cb.successes = 0
+}
+
+// notify calls the OnStateChange callback if the state changed
+func (cb *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && cb.OnStateChange != nil {
+		cb.OnStateChange(from, to)
+	}
+}
+
+// failed reports if the result of a request is a failure for the circuit
+func (cb *CircuitBreaker) failed(resp *http.Response, err error) bool {
+	if err != nil || resp == nil {
+		return true
+	}
+
+	ranges := cb.Ranges
+	if len(ranges) == 0 {
+		ranges = []StatusCodeRange{ServerErrorStatusCodes}
+	}
+
+	return InRanges(resp.StatusCode, ranges)
+}
+
+// CircuitBreak is a Do func middleware that sends the requests through the circuit breaker.
+// Put it before the retry middlewares to have the retries fail fast once the circuit opens,
+// or after them to count each attempt.
+func CircuitBreak(cb *CircuitBreaker) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			probe, err := cb.allow(time.Now())
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next(req)
+
+			ignored := errors.Is(err, context.Canceled)
+			cb.record(probe, cb.failed(resp, err), ignored, time.Now())
+
+			return resp, err
+		}
+
+	}
+}
+
+// defaultInt returns the default when n isn't set
+func defaultInt(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,87 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	changes := []string{}
+	cb := &middleware.CircuitBreaker{
+		FailureThreshold: 3,
+		CoolDown:         20 * time.Millisecond,
+		OnStateChange: func(from, to middleware.CircuitState) {
+			changes = append(changes, from.String()+">"+to.String())
+		},
+	}
+
+	status := 503
+	calls := 0
+	do := middleware.CircuitBreak(cb)(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+	})
+
+	// trip it
+	for i := 0; i < 3; i++ {
+		do(nil)
+	}
+	if cb.State() != middleware.CircuitOpen {
+		t.Fatalf("want open got %v", cb.State())
+	}
+
+	// fails fast without calling next
+	_, err := do(nil)
+	var open middleware.ErrCircuitOpen
+	if !errors.As(err, &open) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls: want 3 got %d", calls)
+	}
+
+	// a failed probe opens it again
+	time.Sleep(25 * time.Millisecond)
+	do(nil)
+	if cb.State() != middleware.CircuitOpen {
+		t.Errorf("want open after a failed probe got %v", cb.State())
+	}
+
+	// a successful probe closes it
+	time.Sleep(25 * time.Millisecond)
+	status = 200
+	do(nil)
+	if cb.State() != middleware.CircuitClosed {
+		t.Errorf("want closed after a successful probe got %v", cb.State())
+	}
+
+	want := []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("want %v got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: want %s got %s", i, want[i], changes[i])
+		}
+	}
+}
+
+func TestCircuitBreakerRanges(t *testing.T) {
+	cb := &middleware.CircuitBreaker{
+		Ranges:           []middleware.StatusCodeRange{{Low: 429, High: 429}},
+		FailureThreshold: 1,
+	}
+
+	do := middleware.CircuitBreak(cb)(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 500, Body: http.NoBody}, nil
+	})
+
+	do(nil)
+	if cb.State() != middleware.CircuitClosed {
+		t.Errorf("want 500 not to be a failure, got %v", cb.State())
+	}
+}
@@ -0,0 +1,245 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// hedgeSamples is how many of the recent latencies are kept for the percentile
+const hedgeSamples = 100
+
+// HedgeConfig is the configuration for the Hedge middleware
+type HedgeConfig struct {
+	// Delay is how long to wait for a response before sending a hedge.
+	// It is also used for the Percentile until there are enough latencies.
+	Delay time.Duration
+
+	// Percentile, like 0.95, waits for that percentile of the recent
+	// latencies before sending a hedge instead of the Delay if it is set
+	Percentile float64
+
+	MaxHedges   int               // extra copies of a request to send, 1 if 0
+	MaxFraction float64           // hedges can't be more than this fraction of the requests, 0.1 if 0
+	Ranges      []StatusCodeRange // status codes that aren't a success, ServerErrorStatusCodes if empty
+}
+
+// hedger keeps the state of the Hedge middleware
+type hedger struct {
+	cfg HedgeConfig
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	budget    float64 // the hedges that can be sent, earned by the requests
+}
+
+// delay returns how long to wait before sending a hedge
+func (h *hedger) delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cfg.Percentile <= 0 || len(h.latencies) < hedgeSamples/5 {
+		return h.cfg.Delay
+	}
+
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(h.cfg.Percentile*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+
+	return sorted[i]
+}
+
+// observe records the latency of a response
+func (h *hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+// request earns the request's fraction of a hedge
+func (h *hedger) request() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.budget += h.cfg.MaxFraction
+	if h.budget > float64(h.cfg.MaxHedges) {
+		h.budget = float64(h.cfg.MaxHedges)
+	}
+}
+
+// withdraw takes a hedge from the budget, reporting false if there isn't one
+func (h *hedger) withdraw() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.budget < 1 {
+		return false
+	}
+	h.budget--
+
+	return true
+}
+
+// hedgeResult is the result of one copy of the request
+type hedgeResult struct {
+	i       int // which copy it is
+	resp    *http.Response
+	err     error
+	cancel  context.CancelFunc
+	elapsed time.Duration
+}
+
+// close closes the body of the response and cancels its request
+func (r hedgeResult) close() {
+	if r.resp != nil && r.resp.Body != nil {
+		io.Copy(io.Discard, r.resp.Body)
+		r.resp.Body.Close()
+	}
+	r.cancel()
+}
+
+// Hedge is a Do func middleware that sends another copy of a GET or HEAD
+// request when there is no response after the delay, up to MaxHedges copies.
+// The first successful response is returned and the other copies are
+// canceled and drained. If all the copies fail the last failure is returned.
+//
+// The hedges are limited to MaxFraction of the requests so hedging can't
+// overload a slow upstream. Each request earns MaxFraction of a hedge and
+// at most MaxHedges can be saved up.
+func Hedge(cfg HedgeConfig) api.Middleware {
+	if cfg.MaxHedges <= 0 {
+		cfg.MaxHedges = 1
+	}
+	if cfg.MaxFraction <= 0 {
+		cfg.MaxFraction = 0.1
+	}
+	if len(cfg.Ranges) == 0 {
+		cfg.Ranges = []StatusCodeRange{ServerErrorStatusCodes}
+	}
+
+	h := &hedger{cfg: cfg, budget: float64(cfg.MaxHedges)}
+
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			if !hedgeable(req) {
+				return next(req)
+			}
+			h.request()
+
+			results := make(chan hedgeResult, cfg.MaxHedges+1)
+			cancels := []context.CancelFunc{}
+			send := func() {
+				ctx, cancel := context.WithCancel(req.Context())
+				attempt := req.Clone(ctx)
+				start := time.Now()
+				i := len(cancels)
+				cancels = append(cancels, cancel)
+
+				go func() {
+					resp, err := next(attempt)
+					results <- hedgeResult{i, resp, err, cancel, time.Since(start)}
+				}()
+			}
+
+			send()
+			outstanding, hedges := 1, 0
+
+			timer := time.NewTimer(h.delay())
+			defer timer.Stop()
+
+			var last hedgeResult
+			for {
+				select {
+				case <-timer.C:
+					if hedges < cfg.MaxHedges && h.withdraw() {
+						send()
+						outstanding++
+						hedges++
+						timer.Reset(h.delay())
+					}
+
+				case res := <-results:
+					outstanding--
+
+					if res.err == nil && res.resp != nil && !InRanges(res.resp.StatusCode, cfg.Ranges) {
+						h.observe(res.elapsed)
+						if last.cancel != nil {
+							last.close()
+						}
+
+						// cancel the losers
+						for i, cancel := range cancels {
+							if i != res.i {
+								cancel()
+							}
+						}
+						drainHedges(results, outstanding)
+						return winner(res), nil
+					}
+
+					if last.cancel != nil {
+						last.close()
+					}
+					last = res
+
+					if outstanding == 0 {
+						return winner(last), last.err
+					}
+				}
+			}
+		}
+
+	}
+}
+
+// hedgeable reports if the request can be sent more than once at the same time
+func hedgeable(req *http.Request) bool {
+	if req.Method != "" && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// winner returns the response with its request canceled once the body is closed
+func winner(res hedgeResult) *http.Response {
+	api.CancelOnClose(res.resp, res.cancel)
+	return res.resp
+}
+
+// drainHedges closes the responses of the outstanding copies
+func drainHedges(results chan hedgeResult, outstanding int) {
+	if outstanding == 0 {
+		return
+	}
+
+	go func() {
+		for i := 0; i < outstanding; i++ {
+			(<-results).close()
+		}
+	}()
+}
@@ -0,0 +1,93 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+// slowFirst blocks the first copy of each request until it is canceled and answers the rest right away
+func slowFirst(calls, canceled *int32) func(req *http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(calls, 1)%2 == 1 {
+			<-req.Context().Done()
+			atomic.AddInt32(canceled, 1)
+			return nil, req.Context().Err()
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("hedge"))}, nil
+	}
+}
+
+func TestHedge(t *testing.T) {
+	var calls, canceled int32
+	do := middleware.Hedge(middleware.HedgeConfig{Delay: 10 * time.Millisecond})(slowFirst(&calls, &canceled))
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+	resp, err := do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hedge" {
+		t.Errorf("want the hedge's response got %q", body)
+	}
+
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("calls: want 2 got %d", calls)
+	}
+
+	// the loser is canceled
+	for i := 0; i < 100 && atomic.LoadInt32(&canceled) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&canceled) != 1 {
+		t.Errorf("expected the first copy to be canceled")
+	}
+}
+
+func TestHedgeMaxFraction(t *testing.T) {
+	var calls int32
+	do := middleware.Hedge(middleware.HedgeConfig{Delay: time.Millisecond, MaxFraction: 0.01})(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+		resp, err := do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// only the first request has a hedge saved up
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("calls: want 4 got %d", n)
+	}
+}
+
+func TestHedgeOnlyGets(t *testing.T) {
+	var calls int32
+	do := middleware.Hedge(middleware.HedgeConfig{Delay: time.Millisecond})(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://localhost/", strings.NewReader("{}"))
+	do(req)
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("calls: want 1 got %d", calls)
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/Reisender/go-api"
+)
+
+// IdempotencyKey is a Do func middleware that sets an Idempotency-Key header
+// on the requests that aren't idempotent, like POST and PATCH, so the retry
+// middlewares will retry them and the server can dedupe the retries.
+//
+// The key is the one from the api.RequestOptions when there is one, or else a
+// new UUIDv4. A key already on the request is kept. Put it before the retry
+// middlewares so each retry sends the same key.
+func IdempotencyKey() api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			if IdempotentMethods[req.Method] || req.Header.Get("Idempotency-Key") != "" {
+				return next(req)
+			}
+
+			key := requestOptions(req).IdempotencyKey
+			if key == "" {
+				var err error
+				key, err = NewIdempotencyKey()
+				if err != nil {
+					return nil, err
+				}
+			}
+			req.Header.Set("Idempotency-Key", key)
+
+			return next(req)
+		}
+
+	}
+}
+
+// NewIdempotencyKey returns a random UUIDv4
+func NewIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,63 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		ctx    context.Context
+		header string
+		want   string // "uuid" for a generated key
+	}{
+		{"post", http.MethodPost, context.Background(), "", "uuid"},
+		{"patch", http.MethodPatch, context.Background(), "", "uuid"},
+		{"get", http.MethodGet, context.Background(), "", ""},
+		{"from context", http.MethodPost, api.WithRequestOptions(context.Background(), api.IdempotencyKey("abc")), "", "abc"},
+		{"get from context", http.MethodGet, api.WithRequestOptions(context.Background(), api.IdempotencyKey("abc")), "", ""},
+		{"already set", http.MethodPost, context.Background(), "xyz", "xyz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(tt.ctx, tt.method, "http://localhost/", strings.NewReader("{}"))
+			if tt.header != "" {
+				req.Header.Set("Idempotency-Key", tt.header)
+			}
+
+			keys := []string{}
+			middleware.IdempotencyKey()(middleware.RetryWithBackoff(2, middleware.ConstantBackoff(0))(func(req *http.Request) (*http.Response, error) {
+				keys = append(keys, req.Header.Get("Idempotency-Key"))
+				return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+			}))(req)
+
+			for i, key := range keys {
+				if tt.want == "uuid" && !uuidV4.MatchString(key) {
+					t.Errorf("try %d: want a UUIDv4 got %q", i, key)
+				} else if tt.want != "uuid" && key != tt.want {
+					t.Errorf("try %d: want %q got %q", i, tt.want, key)
+				}
+
+				if key != keys[0] {
+					t.Errorf("try %d: want the key to stay %q got %q", i, keys[0], key)
+				}
+			}
+
+			// GETs are idempotent and the rest have a key so all are retried
+			if len(keys) != 3 {
+				t.Errorf("tries: want 3 got %d", len(keys))
+			}
+		})
+	}
+}
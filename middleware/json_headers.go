@@ -26,9 +26,26 @@ func NewExampleMiddleware(val string) api.Middleware {
 
 // JSONPayload is a Do func middleware that sets the JSON payload headers
 func JSONHeaders(next api.Dofn) api.Dofn {
-	return func(req *http.Request) (*http.Response, error) {
-		req.Header.Add("Accept", "application/json")
-		req.Header.Add("Content-Type", "application/json")
-		return next(req)
+	return CodecHeaders(api.JSON)(next)
+}
+
+// CodecHeaders sets the payload headers for the media type of the codec.
+// Headers already on the request are kept and the Content-Type is only set
+// when the request has a body.
+func CodecHeaders(codec api.Codec) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Accept") == "" {
+				req.Header.Set("Accept", codec.MediaType())
+			}
+			if req.Body != nil && req.Body != http.NoBody && req.Header.Get("Content-Type") == "" {
+				req.Header.Set("Content-Type", codec.MediaType())
+			}
+			return next(req)
+		}
+
 	}
 }
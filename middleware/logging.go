@@ -0,0 +1,335 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// redacted replaces the values that shouldn't be logged
+const redacted = "REDACTED"
+
+// LoggingOption configures the Logging middleware
+type LoggingOption func(*logging)
+
+// logging is the configuration for the Logging middleware
+type logging struct {
+	logger    *slog.Logger
+	headers   bool
+	bodyLimit int
+	redact    map[string]bool // lowercase header names
+	query     map[string]bool
+	fields    map[string]bool // lowercase JSON field names
+	levels    map[int]slog.Level
+	errLevel  slog.Level
+}
+
+// LogHeaders logs the request and response headers
+func LogHeaders() LoggingOption {
+	return func(l *logging) {
+		l.headers = true
+	}
+}
+
+// LogBodies logs up to limit bytes of the request and response bodies
+func LogBodies(limit int) LoggingOption {
+	return func(l *logging) {
+		l.bodyLimit = limit
+	}
+}
+
+// RedactHeaders masks the headers on top of Authorization,
+// Proxy-Authorization, Cookie and Set-Cookie
+func RedactHeaders(names ...string) LoggingOption {
+	return func(l *logging) {
+		for _, name := range names {
+			l.redact[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// RedactQuery masks the query params in the URL
+func RedactQuery(params ...string) LoggingOption {
+	return func(l *logging) {
+		for _, p := range params {
+			l.query[p] = true
+		}
+	}
+}
+
+// RedactJSONFields masks the fields, at any depth, in the JSON bodies.
+// A JSON body that can't be parsed, like one cut off at the limit, is
+// masked completely.
+func RedactJSONFields(fields ...string) LoggingOption {
+	return func(l *logging) {
+		for _, f := range fields {
+			l.fields[strings.ToLower(f)] = true
+		}
+	}
+}
+
+// LogLevel sets the level for the responses in the status class, like 4 for the 4XX status codes.
+// By default 4XX are logged as warnings, 5XX as errors and the rest as info.
+func LogLevel(class int, level slog.Level) LoggingOption {
+	return func(l *logging) {
+		l.levels[class] = level
+	}
+}
+
+// LogErrorLevel sets the level for the requests that fail without a response, error by default
+func LogErrorLevel(level slog.Level) LoggingOption {
+	return func(l *logging) {
+		l.errLevel = level
+	}
+}
+
+// Logging is a Do func middleware that logs each request with the method, URL,
+// status, latency, attempt and the request and response sizes. The Authorization,
+// Proxy-Authorization, Cookie and Set-Cookie headers are always masked.
+// Put it after the retry middlewares to log each attempt. A nil logger uses slog.Default().
+func Logging(logger *slog.Logger, opts ...LoggingOption) api.Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	l := &logging{
+		logger: logger,
+		redact: map[string]bool{
+			"authorization":       true,
+			"proxy-authorization": true,
+			"cookie":              true,
+			"set-cookie":          true,
+		},
+		query:    map[string]bool{},
+		fields:   map[string]bool{},
+		levels:   map[int]slog.Level{4: slog.LevelWarn, 5: slog.LevelError},
+		errLevel: slog.LevelError,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("url", l.url(req)),
+				slog.Int("attempt", Attempt(req)),
+			}
+
+			if l.headers {
+				attrs = append(attrs, slog.Any("request_headers", l.header(req.Header)))
+			}
+
+			reqSize := requestSize(req)
+			if l.bodyLimit > 0 {
+				body, eof, err := l.requestBody(req)
+				if err != nil {
+					return nil, err
+				}
+				if eof {
+					reqSize = int64(len(body))
+				}
+				attrs = append(attrs, slog.String("request_body", l.body(req.Header, body)))
+			}
+			if reqSize >= 0 {
+				attrs = append(attrs, slog.Int64("request_size", reqSize))
+			}
+
+			start := time.Now()
+			resp, err := next(req)
+			attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+
+			if err != nil || resp == nil {
+				if err != nil {
+					attrs = append(attrs, slog.String("error", err.Error()))
+				}
+				l.logger.LogAttrs(req.Context(), l.errLevel, "http request", attrs...)
+				return resp, err
+			}
+
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+
+			if l.headers {
+				attrs = append(attrs, slog.Any("response_headers", l.header(resp.Header)))
+			}
+
+			respSize := resp.ContentLength
+			if l.bodyLimit > 0 && resp.Body != nil && !streaming(resp.Header) {
+				var body []byte
+				var eof bool
+				body, eof, resp.Body = peekBody(resp.Body, l.bodyLimit)
+				if eof {
+					respSize = int64(len(body))
+				}
+				attrs = append(attrs, slog.String("response_body", l.body(resp.Header, body)))
+			}
+			if respSize >= 0 {
+				attrs = append(attrs, slog.Int64("response_size", respSize))
+			}
+
+			level, ok := l.levels[resp.StatusCode/100]
+			if !ok {
+				level = slog.LevelInfo
+			}
+			l.logger.LogAttrs(req.Context(), level, "http request", attrs...)
+
+			return resp, err
+		}
+
+	}
+}
+
+// url returns the URL with the password and the redacted query params masked
+func (l *logging) url(req *http.Request) string {
+	if req.URL == nil {
+		return ""
+	}
+
+	u := *req.URL
+	if len(l.query) > 0 && u.RawQuery != "" {
+		q := u.Query()
+		for p := range q {
+			if l.query[p] {
+				q[p] = []string{redacted}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	return u.Redacted()
+}
+
+// header returns a copy of the header with the redacted headers masked
+func (l *logging) header(h http.Header) http.Header {
+	out := h.Clone()
+	for name := range out {
+		if l.redact[strings.ToLower(name)] {
+			out[name] = []string{redacted}
+		}
+	}
+
+	return out
+}
+
+// body returns the body to log with the redacted JSON fields masked
+func (l *logging) body(h http.Header, body []byte) string {
+	if len(l.fields) == 0 || len(body) == 0 || !strings.Contains(h.Get("Content-Type"), "json") {
+		return string(body)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return redacted
+	}
+
+	masked, err := json.Marshal(l.redactFields(v))
+	if err != nil {
+		return redacted
+	}
+
+	return string(masked)
+}
+
+// redactFields masks the redacted fields in the decoded JSON
+func (l *logging) redactFields(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if l.fields[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = l.redactFields(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = l.redactFields(val)
+		}
+	}
+
+	return v
+}
+
+// requestBody returns up to the limit of the request body without consuming it
+// and if that is all of the body
+func (l *logging) requestBody(req *http.Request) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, false, err
+		}
+		defer rc.Close()
+
+		body, eof, _ := peekBody(rc, l.bodyLimit)
+		return body, eof, nil
+	}
+
+	var body []byte
+	var eof bool
+	body, eof, req.Body = peekBody(req.Body, l.bodyLimit)
+
+	return body, eof, nil
+}
+
+// requestSize is the size of the request body, -1 if it isn't known
+func requestSize(req *http.Request) int64 {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 0
+	}
+
+	// a 0 ContentLength with a body is unknown too
+	if req.ContentLength <= 0 {
+		return -1
+	}
+
+	return req.ContentLength
+}
+
+// streamingTypes are the content types of the responses that are read as a
+// stream, so their bodies aren't logged since waiting for them would block
+var streamingTypes = []string{"text/event-stream", "application/x-ndjson"}
+
+// streaming reports if the body is a stream
+func streaming(h http.Header) bool {
+	ct := strings.ToLower(h.Get("Content-Type"))
+	for _, st := range streamingTypes {
+		if strings.HasPrefix(ct, st) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peekBody reads up to limit bytes of the body and returns them, if that
+// is all of the body and a body that still reads from the start
+func peekBody(rc io.ReadCloser, limit int) ([]byte, bool, io.ReadCloser) {
+	// read one more byte to know if there is more
+	buf, err := io.ReadAll(io.LimitReader(rc, int64(limit)+1))
+
+	body := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), rc), rc}
+
+	if err != nil || len(buf) > limit {
+		return buf[:min(len(buf), limit)], false, body
+	}
+
+	return buf, true, body
+}
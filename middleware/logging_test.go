@@ -0,0 +1,208 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+// logged runs the request through the Logging middleware and returns the log entry
+func logged(t *testing.T, req *http.Request, resp *http.Response, err error, opts ...middleware.LoggingOption) map[string]interface{} {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	got, _ := middleware.Logging(logger, opts...)(func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			io.ReadAll(req.Body)
+		}
+		return resp, err
+	})(req)
+
+	if got != nil && got.Body != nil {
+		body, _ := io.ReadAll(got.Body)
+		if string(body) != `{"token":"abc","ok":true}` {
+			t.Errorf("expected the response body to still be readable, got %q", body)
+		}
+	}
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("could not parse the log %q: %v", buf.String(), err)
+	}
+
+	return entry
+}
+
+func TestLogging(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://localhost/v1/foo?key=secret&page=2", strings.NewReader(`{"password":"hunter2","user":{"name":"bob","ssn":"123"}}`))
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("X-Api-Key", "abc")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := &http.Response{
+		StatusCode:    404,
+		ContentLength: -1, // chunked
+		Header:        http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"session=abc"}},
+		Body:          io.NopCloser(strings.NewReader(`{"token":"abc","ok":true}`)),
+	}
+
+	entry := logged(t, req, resp, nil,
+		middleware.LogHeaders(),
+		middleware.LogBodies(1024),
+		middleware.RedactHeaders("X-Api-Key"),
+		middleware.RedactQuery("key"),
+		middleware.RedactJSONFields("password", "ssn", "token"),
+	)
+
+	want := map[string]interface{}{
+		"level":         "WARN",
+		"method":        "POST",
+		"url":           "http://localhost/v1/foo?key=REDACTED&page=2",
+		"status":        float64(404),
+		"attempt":       float64(1),
+		"request_size":  float64(56),
+		"response_size": float64(25),
+		"request_body":  `{"password":"REDACTED","user":{"name":"bob","ssn":"REDACTED"}}`,
+		"response_body": `{"ok":true,"token":"REDACTED"}`,
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s: want %v got %v", k, v, entry[k])
+		}
+	}
+
+	headers, _ := json.Marshal(entry["request_headers"])
+	if strings.Contains(string(headers), "abc") {
+		t.Errorf("expected the request headers to be redacted, got %s", headers)
+	}
+
+	headers, _ = json.Marshal(entry["response_headers"])
+	if strings.Contains(string(headers), "session") {
+		t.Errorf("expected the response headers to be redacted, got %s", headers)
+	}
+}
+
+func TestLoggingLevels(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		opts   []middleware.LoggingOption
+		want   string
+	}{
+		{"ok", 200, nil, nil, "INFO"},
+		{"client error", 400, nil, nil, "WARN"},
+		{"server error", 503, nil, nil, "ERROR"},
+		{"error", 0, errors.New("connection reset"), nil, "ERROR"},
+		{"custom", 200, nil, []middleware.LoggingOption{middleware.LogLevel(2, slog.LevelDebug)}, "DEBUG"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+
+			entry := logged(t, req, resp, tt.err, tt.opts...)
+			if entry["level"] != tt.want {
+				t.Errorf("want %s got %v", tt.want, entry["level"])
+			}
+		})
+	}
+}
+
+func TestLoggingAttempt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	tries := 0
+	do := middleware.RetryWithBackoff(1, middleware.ConstantBackoff(0))(middleware.Logging(logger)(func(req *http.Request) (*http.Response, error) {
+		tries++
+		return &http.Response{StatusCode: 500 + tries, Body: http.NoBody}, nil
+	}))
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+	do(req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"attempt":1`) || !strings.Contains(lines[1], `"attempt":2`) {
+		t.Errorf("expected attempts 1 and 2, got %v", lines)
+	}
+}
+
+func TestLoggingSizes(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://localhost/", io.NopCloser(strings.NewReader("streamed")))
+	resp := &http.Response{
+		StatusCode:    200,
+		ContentLength: -1,
+		Body:          io.NopCloser(strings.NewReader(`{"token":"abc","ok":true}`)),
+	}
+
+	// the sizes aren't known without reading the bodies
+	entry := logged(t, req, resp, nil)
+	if _, ok := entry["request_size"]; ok {
+		t.Errorf("expected no request_size, got %v", entry["request_size"])
+	}
+	if _, ok := entry["response_size"]; ok {
+		t.Errorf("expected no response_size, got %v", entry["response_size"])
+	}
+}
+
+func TestLoggingBodyLimit(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+	resp := &http.Response{
+		StatusCode:    200,
+		ContentLength: -1,
+		Body:          io.NopCloser(strings.NewReader(`{"token":"abc","ok":true}`)),
+	}
+
+	entry := logged(t, req, resp, nil, middleware.LogBodies(5))
+	if entry["response_body"] != `{"tok` {
+		t.Errorf("want the first 5 bytes got %v", entry["response_body"])
+	}
+	if _, ok := entry["response_size"]; ok {
+		t.Errorf("expected no response_size, got %v", entry["response_size"])
+	}
+}
+
+func TestLoggingStreamingResponse(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	// a stream that hasn't sent anything yet
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+		middleware.Logging(logger, middleware.LogBodies(1024))(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, ContentLength: -1, Header: http.Header{"Content-Type": {"text/event-stream"}}, Body: pr}, nil
+		})(req)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the stream not to be read before returning")
+	}
+
+	if strings.Contains(buf.String(), "response_body") {
+		t.Errorf("expected no response body to be logged, got %s", buf.String())
+	}
+}
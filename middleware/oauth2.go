@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// OAuth2Config configures the OAuth2ClientCredentials middleware
+type OAuth2Config struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams url.Values    // extra token request params like audience
+	AuthInParams   bool          // send the client credentials in the body instead of with basic auth
+	HTTPClient     *http.Client  // used for the token requests, http.DefaultClient if nil
+	ExpiryDelta    time.Duration // refresh this long before the token expires, 10s if 0
+}
+
+// OAuth2ClientCredentials is a Do func middleware that authorizes requests with
+// a token from the OAuth2 client credentials flow.
+// The token is cached until shortly before it expires and is refreshed under a
+// lock so concurrent requests share one token request. A request that gets a
+// 401 is retried once with a fresh token if its body can be replayed.
+func OAuth2ClientCredentials(cfg OAuth2Config) api.Middleware {
+	return BearerTokenSource(OAuth2TokenSource(cfg))
+}
+
+// OAuth2TokenSource is a cached TokenSource for the OAuth2 client credentials flow
+func OAuth2TokenSource(cfg OAuth2Config) TokenSource {
+	delta := cfg.ExpiryDelta
+	if delta == 0 {
+		delta = 10 * time.Second
+	}
+
+	return CachedToken(&clientCredentials{cfg: cfg}, delta)
+}
+
+// clientCredentials fetches client credentials tokens
+type clientCredentials struct {
+	cfg OAuth2Config
+}
+
+// Token requests a new token from the token endpoint
+func (cc *clientCredentials) Token(ctx context.Context) (string, time.Time, error) {
+	params := url.Values{}
+	for k, v := range cc.cfg.EndpointParams {
+		params[k] = v
+	}
+	params.Set("grant_type", "client_credentials")
+	if len(cc.cfg.Scopes) > 0 {
+		params.Set("scope", strings.Join(cc.cfg.Scopes, " "))
+	}
+	if cc.cfg.AuthInParams {
+		params.Set("client_id", cc.cfg.ClientID)
+		params.Set("client_secret", cc.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cc.cfg.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !cc.cfg.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(cc.cfg.ClientID), url.QueryEscape(cc.cfg.ClientSecret))
+	}
+
+	client := cc.cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: token request: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: token request: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", time.Time{}, fmt.Errorf("oauth2: token request: %w", APIError{
+			Status:  res.Status,
+			Code:    res.StatusCode,
+			Header:  res.Header,
+			Body:    body,
+			Problem: decodeProblem(res.Header.Get("Content-Type"), body),
+		})
+	}
+
+	tok := struct {
+		AccessToken string      `json:"access_token"`
+		TokenType   string      `json:"token_type"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}{}
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(&tok)
+	if err != nil {
+		return "", time.Time{}, api.ParseError{Raw: body, Err: err}
+	}
+	if tok.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("oauth2: token response has no access_token")
+	}
+
+	if tok.TokenType != "" && !strings.EqualFold(tok.TokenType, "bearer") {
+		return "", time.Time{}, fmt.Errorf("oauth2: unsupported token type %q", tok.TokenType)
+	}
+
+	var expiry time.Time
+	if secs, err := tok.ExpiresIn.Int64(); err == nil && secs > 0 {
+		expiry = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+
+	return tok.AccessToken, expiry, nil
+}
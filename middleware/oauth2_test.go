@@ -0,0 +1,143 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+// newTokenServer returns a token server that issues token-1, token-2, ...
+func newTokenServer(t *testing.T, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != "client_credentials" {
+			t.Errorf("grant_type: want 'client_credentials' got '%s'", got)
+		}
+		if got := r.PostForm.Get("scope"); got != "read write" {
+			t.Errorf("scope: want 'read write' got '%s'", got)
+		}
+
+		n := atomic.AddInt32(issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var issued int32
+	tokenSrv := newTokenServer(t, &issued)
+	defer tokenSrv.Close()
+
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer apiSrv.Close()
+
+	c := api.NewClient(apiSrv.URL, "", 0, middleware.OAuth2ClientCredentials(middleware.OAuth2Config{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	}))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), "/foo")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != 200 {
+				t.Errorf("status: want 200 got %d", resp.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if issued := atomic.LoadInt32(&issued); issued != 1 {
+		t.Errorf("expected concurrent requests to share 1 token, %d were issued", issued)
+	}
+}
+
+func TestOAuth2ClientCredentialsRefreshOn401(t *testing.T) {
+	var issued int32
+	tokenSrv := newTokenServer(t, &issued)
+	defer tokenSrv.Close()
+
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first token has been revoked
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		buf := make([]byte, 64)
+		n, _ := r.Body.Read(buf)
+		if string(buf[:n]) != "payload" {
+			t.Errorf("body: want 'payload' got '%s'", buf[:n])
+		}
+	}))
+	defer apiSrv.Close()
+
+	c := api.NewClient(apiSrv.URL, "", 0, middleware.OAuth2ClientCredentials(middleware.OAuth2Config{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	}))
+
+	resp, err := c.Post(context.Background(), "/foo", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Errorf("status: want 200 got %d", resp.StatusCode)
+	}
+
+	if issued := atomic.LoadInt32(&issued); issued != 2 {
+		t.Errorf("expected a fresh token after the 401, %d were issued", issued)
+	}
+}
+
+func TestOAuth2ClientCredentialsTokenError(t *testing.T) {
+	var issued int32
+	tokenSrv := newTokenServer(t, &issued)
+	defer tokenSrv.Close()
+
+	c := api.NewClient("http://localhost", "", 0, middleware.OAuth2ClientCredentials(middleware.OAuth2Config{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "client",
+		ClientSecret: "wrong",
+	}))
+
+	_, err := c.Get(context.Background(), "/foo")
+	if err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("expected an invalid_client error, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// Limiter blocks until a request is allowed to be sent
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket is a Limiter that allows bursts of up to burst requests and
+// refills at rate requests per second.
+type TokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a full TokenBucket.
+// A rate of 0 or less never refills, so after the burst Wait blocks until
+// the context is done.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait takes a token from the bucket, waiting for one if it is empty.
+// Waiters are served in order. It returns the context's error if the
+// context is done first, or right away if its deadline is before the
+// token would be available.
+func (tb *TokenBucket) Wait(ctx context.Context) error {
+	delay := tb.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		tb.cancel()
+		return context.DeadlineExceeded
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		tb.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token, letting the bucket go negative so waiters queue up,
+// and returns how long until the token is actually available
+func (tb *TokenBucket) reserve() time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := time.Now()
+	if tb.rate > 0 {
+		tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+
+	// the bucket never refills
+	if tb.rate <= 0 {
+		return math.MaxInt64
+	}
+
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// cancel gives back a reserved token
+func (tb *TokenBucket) cancel() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.tokens++
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+}
+
+// RateLimit is a Do func middleware that waits on the limiter before each request
+func RateLimit(l Limiter) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			err := l.Wait(req.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			return next(req)
+		}
+
+	}
+}
+
+// RateLimitBy is a Do func middleware that keeps a limiter for each key of
+// the requests. The limiter for a key is created with newLimiter the first
+// time the key is seen.
+func RateLimitBy(key func(req *http.Request) string, newLimiter func(key string) Limiter) api.Middleware {
+	limiters := newKeyed(newLimiter)
+
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			err := limiters.get(key(req)).Wait(req.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			return next(req)
+		}
+
+	}
+}
+
+// RateLimitPerHost is a Do func middleware that limits each host separately
+func RateLimitPerHost(rate float64, burst int) api.Middleware {
+	return RateLimitBy(requestHost, func(string) Limiter {
+		return NewTokenBucket(rate, burst)
+	})
+}
+
+// RouteLimit is the limiter for the requests that match a route
+type RouteLimit struct {
+	Method  string // the method to match, any method if empty
+	Pattern string // the path pattern to match using path.Match syntax like "/v1/users/*"
+	Limiter Limiter
+}
+
+// matches reports if the request is for the route
+func (rl RouteLimit) matches(req *http.Request) bool {
+	if rl.Method != "" && rl.Method != req.Method {
+		return false
+	}
+
+	ok, err := path.Match(rl.Pattern, req.URL.Path)
+	return err == nil && ok
+}
+
+// RateLimitPerRoute is a Do func middleware that waits on the limiter of the
+// first route that matches the request. Requests that don't match a route
+// aren't limited.
+func RateLimitPerRoute(routes ...RouteLimit) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			for _, route := range routes {
+				if !route.matches(req) {
+					continue
+				}
+
+				err := route.Limiter.Wait(req.Context())
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+
+			return next(req)
+		}
+
+	}
+}
+
+// requestHost is the key func for per host middleware
+func requestHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+// keyed lazily creates a value for each key
+type keyed[T any] struct {
+	mu     sync.Mutex
+	values map[string]T
+	create func(key string) T
+}
+
+func newKeyed[T any](create func(key string) T) *keyed[T] {
+	return &keyed[T]{
+		values: map[string]T{},
+		create: create,
+	}
+}
+
+// get returns the value for the key, creating it if needed
+func (k *keyed[T]) get(key string) T {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	v, ok := k.values[key]
+	if !ok {
+		v = k.create(key)
+		k.values[key] = v
+	}
+
+	return v
+}
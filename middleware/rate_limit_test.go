@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tb := middleware.NewTokenBucket(100, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := tb.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the burst of 2 is free and the next 2 take 10ms each
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected the requests past the burst to wait, took %v", elapsed)
+	}
+}
+
+func TestTokenBucketContext(t *testing.T) {
+	tb := middleware.NewTokenBucket(1, 1)
+	tb.Wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := tb.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+
+	// a token is a second away so this should not have waited for it
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected to return without waiting for the token, took %v", elapsed)
+	}
+}
+
+func TestTokenBucketZeroRate(t *testing.T) {
+	tb := middleware.NewTokenBucket(0, 1)
+
+	if err := tb.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the burst is allowed so the rest wait for the context
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		if err := tb.Wait(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("request %d: expected to wait for the context, got %v", i+2, err)
+		}
+		cancel()
+	}
+}
+
+func TestRateLimitPerRoute(t *testing.T) {
+	limited := 0
+	limiter := limiterFunc(func(ctx context.Context) error {
+		limited++
+		return nil
+	})
+
+	m := middleware.RateLimitPerRoute(middleware.RouteLimit{
+		Method:  http.MethodGet,
+		Pattern: "/v1/users/*",
+		Limiter: limiter,
+	})
+
+	do := m(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200}, nil
+	})
+
+	for _, url := range []string{"http://localhost/v1/users/7", "http://localhost/v1/orders/7", "http://localhost/v1/users/8"} {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		if _, err := do(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if limited != 2 {
+		t.Errorf("expected 2 requests to match the route, got %d", limited)
+	}
+}
+
+func TestRateLimitPerHost(t *testing.T) {
+	m := middleware.RateLimitPerHost(1, 1)
+	do := m(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// each host gets its own burst
+	for _, url := range []string{"http://a.example.com", "http://b.example.com"} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if _, err := do(req); err != nil {
+			t.Errorf("%s: %v", url, err)
+		}
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://a.example.com", nil)
+	if _, err := do(req); err == nil {
+		t.Error("expected the second request to the same host to be limited")
+	}
+}
+
+// limiterFunc allows a func to be used as a Limiter
+type limiterFunc func(ctx context.Context) error
+
+func (f limiterFunc) Wait(ctx context.Context) error {
+	return f(ctx)
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Reisender/go-api"
+)
+
+// requestOptions returns the api.RequestOptions carried on the request.
+// It is safe to call with a nil request.
+func requestOptions(req *http.Request) api.RequestOptions {
+	if req == nil {
+		return api.RequestOptions{}
+	}
+
+	return api.RequestOptionsFrom(req.Context())
+}
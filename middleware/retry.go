@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -21,21 +22,69 @@ func (e ErrMaxRetries) Unwrap() error {
 	return e.Err
 }
 
-// RetryOnStatusCodes is a Do func middleware that will retry based on status codes
+// withdrawRetry takes the retry from the retry budget on the request
+func withdrawRetry(req *http.Request) error {
+	if !retryBudget(req).Withdraw() {
+		return ErrMaxRetries{ErrRetryBudgetExhausted}
+	}
+
+	return nil
+}
+
+// prepareRetry is done with the response being retried, gets the request
+// ready to be sent again and notifies the retry hooks
+func prepareRetry(req *http.Request, resp *http.Response, attempt int, delay time.Duration) error {
+	// the request can't be sent again while the response body is open
+	if resp != nil && resp.Body != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	err := rewindBody(req)
+	if err != nil {
+		return err
+	}
+
+	api.NotifyRetry(req, attempt, delay)
+
+	return nil
+}
+
+// RetryOnStatusCodes is a Do func middleware that will retry based on status codes.
+// Like all the retry middlewares, only requests with IdempotentMethods or an
+// Idempotency-Key header are retried and the body is replayed on each retry.
+// Each attempt gets its own timeout when the api.AttemptTimeout request option is set.
 func RetryOnStatusCodes(retry uint, statusCodes ...StatusCodeRange) api.Middleware {
+	return RetryOnStatusCodesTimeout(retry, 0, statusCodes...)
+}
+
+// RetryOnStatusCodesTimeout is RetryOnStatusCodes with a timeout for each attempt,
+// none if it is 0. The api.AttemptTimeout request option overrides it.
+func RetryOnStatusCodesTimeout(retry uint, attemptTimeout time.Duration, statusCodes ...StatusCodeRange) api.Middleware {
 	// return the middleware func
 	return func(next api.Dofn) api.Dofn {
 
 		// return the Do func
 		return func(req *http.Request) (*http.Response, error) {
 
+			maxRetries := retry
+			if requestOptions(req).DisableRetries || !retryable(req) {
+				maxRetries = 0
+			}
+
 			// If there is an error, resp can be nil
-			resp, err := next(req)
+			resp, err := sendAttempt(next, req, 1, attemptTimeout)
 
 			retryCount := uint(0)
-			for retryCount < retry && (resp == nil || InRanges(resp.StatusCode, statusCodes)) {
+			for retryCount < maxRetries && (resp == nil || InRanges(resp.StatusCode, statusCodes)) {
 				retryCount++
-				resp, err = next(req)
+				if err := withdrawRetry(req); err != nil {
+					return resp, err
+				}
+				if err := prepareRetry(req, resp, int(retryCount), 0); err != nil {
+					return nil, err
+				}
+				resp, err = sendAttempt(next, req, int(retryCount)+1, attemptTimeout)
 				if err != nil {
 					return nil, ErrMaxRetries{err}
 				}
@@ -54,6 +103,25 @@ var Non2XXStatusCodes = StatusCodeRange{Low: 300, High: 599}
 // It also can take a delay min, max, and backoff multiplier. If no range is passed, it defaults
 // to Non2XXStatusCodes range
 func RetryWithDelay(retry uint, delayMin, delayMax time.Duration, delayRamp float32, ranges ...StatusCodeRange) api.Middleware {
+	return RetryWithDelayTimeout(retry, 0, delayMin, delayMax, delayRamp, ranges...)
+}
+
+// RetryWithDelayTimeout is RetryWithDelay with a timeout for each attempt,
+// none if it is 0. The api.AttemptTimeout request option overrides it.
+func RetryWithDelayTimeout(retry uint, attemptTimeout, delayMin, delayMax time.Duration, delayRamp float32, ranges ...StatusCodeRange) api.Middleware {
+	return RetryWithBackoffTimeout(retry, attemptTimeout, ExponentialBackoff(delayMin, delayMax, float64(delayRamp)), ranges...)
+}
+
+// RetryWithBackoff is a Do func middleware that will retry based on status codes or on err,
+// waiting between the retries for the delay from the backoff. If no range is passed, it
+// defaults to Non2XXStatusCodes range
+func RetryWithBackoff(retry uint, backoff Backoff, ranges ...StatusCodeRange) api.Middleware {
+	return RetryWithBackoffTimeout(retry, 0, backoff, ranges...)
+}
+
+// RetryWithBackoffTimeout is RetryWithBackoff with a timeout for each attempt,
+// none if it is 0. The api.AttemptTimeout request option overrides it.
+func RetryWithBackoffTimeout(retry uint, attemptTimeout time.Duration, backoff Backoff, ranges ...StatusCodeRange) api.Middleware {
 	// default to the Non2XXStatusCodes
 	if len(ranges) == 0 {
 		ranges = []StatusCodeRange{Non2XXStatusCodes}
@@ -64,29 +132,33 @@ func RetryWithDelay(retry uint, delayMin, delayMax time.Duration, delayRamp floa
 
 		// return the Do func
 		return func(req *http.Request) (*http.Response, error) {
+			maxRetries := retry
+			if requestOptions(req).DisableRetries || !retryable(req) {
+				maxRetries = 0
+			}
 
 			// If there is an error, resp can be nil
-			resp, err := next(req)
+			resp, err := sendAttempt(next, req, 1, attemptTimeout)
 
-			// retry on status code >= 300 or err from next
+			// retry on status codes in the ranges or err from next
 			retryCount := uint(0)
-			delay := delayMin
-			for retryCount < retry && (err != nil || resp == nil || InRanges(resp.StatusCode, ranges)) {
+			delay := time.Duration(0)
+			for retryCount < maxRetries && (err != nil || resp == nil || InRanges(resp.StatusCode, ranges)) {
+				delay = backoff.Delay(retryCount, delay)
 				retryCount++
+				if err := withdrawRetry(req); err != nil {
+					return resp, err
+				}
+				if err := prepareRetry(req, resp, int(retryCount), delay); err != nil {
+					return nil, err
+				}
+
 				select {
 				case <-req.Context().Done():
 					return nil, req.Context().Err()
 				case <-time.After(delay):
-					// update the delay for the backoff
-					if delay < delayMax {
-						delay = time.Duration(float32(delay) * delayRamp)
-					}
-					if delay > delayMax {
-						delay = delayMax
-					}
-
 					// try again
-					resp, err = next(req)
+					resp, err = sendAttempt(next, req, int(retryCount)+1, attemptTimeout)
 				}
 			}
 
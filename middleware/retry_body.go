@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// MaxRetryBodySize is how much of a request body without a GetBody is
+// buffered so it can be sent again on a retry. Requests with larger bodies
+// are sent once and not retried.
+var MaxRetryBodySize int64 = 1 << 20
+
+// IdempotentMethods are the methods that are retried by default.
+// Requests with other methods, like POST and PATCH, are only retried
+// when they have an Idempotency-Key header.
+var IdempotentMethods = map[string]bool{
+	"":                 true, // the http.Client sends GET
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodTrace:   true,
+}
+
+// retryable reports if the request can be sent again by the retry middlewares.
+// It must be called before the first attempt since a body without a GetBody
+// is buffered so it can be replayed. It is safe to call with a nil request.
+func retryable(req *http.Request) bool {
+	if req == nil {
+		return true
+	}
+
+	if !IdempotentMethods[req.Method] && req.Header.Get("Idempotency-Key") == "" {
+		return false
+	}
+
+	return replayable(req)
+}
+
+// replayable makes sure the body of the request can be read again through GetBody
+func replayable(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(req.Body, MaxRetryBodySize+1))
+	if err != nil || int64(len(buf)) > MaxRetryBodySize {
+		// send what was read followed by the rest, just the once
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+		return false
+	}
+	req.Body.Close()
+
+	req.Body = io.NopCloser(bytes.NewReader(buf))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+
+	return true
+}
+
+// rewindBody resets the body of the request to send it again
+func rewindBody(req *http.Request) error {
+	if req == nil || req.GetBody == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+
+	return nil
+}
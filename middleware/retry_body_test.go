@@ -0,0 +1,122 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestRetryReplaysBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		key       string
+		getBody   bool
+		body      string
+		wantTries int
+	}{
+		{"put with GetBody", http.MethodPut, "", true, "payload", 3},
+		{"put buffered", http.MethodPut, "", false, "payload", 3},
+		{"post", http.MethodPost, "", false, "payload", 1},
+		{"post with key", http.MethodPost, "abc", false, "payload", 3},
+		{"patch with key", http.MethodPatch, "abc", true, "payload", 3},
+		{"too big to buffer", http.MethodPut, "", false, strings.Repeat("x", int(middleware.MaxRetryBodySize)+1), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(context.Background(), tt.method, "http://localhost/", nil)
+			req.Body = io.NopCloser(strings.NewReader(tt.body))
+			if tt.getBody {
+				req.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(tt.body)), nil
+				}
+			}
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
+			}
+
+			tries := 0
+			middleware.RetryWithBackoff(2, middleware.ConstantBackoff(0))(func(req *http.Request) (*http.Response, error) {
+				tries++
+				body, _ := io.ReadAll(req.Body)
+				if string(body) != tt.body {
+					t.Errorf("try %d: want a body of %d bytes got %d", tries, len(tt.body), len(body))
+				}
+				return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+			})(req)
+
+			if tries != tt.wantTries {
+				t.Errorf("tries: want %d got %d", tt.wantTries, tries)
+			}
+		})
+	}
+}
+
+func TestRetryOnStatusCodesReplaysBody(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPut, "http://localhost/", strings.NewReader("payload"))
+
+	tries := 0
+	middleware.RetryOnStatusCodes(2, middleware.StatusCodeRange{Low: 500, High: 599})(func(req *http.Request) (*http.Response, error) {
+		tries++
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != "payload" {
+			t.Errorf("try %d: want payload got %q", tries, body)
+		}
+		return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+	})(req)
+
+	if tries != 3 {
+		t.Errorf("tries: want 3 got %d", tries)
+	}
+}
+
+// trackedBody records when it is closed
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRetryClosesRetriedResponses(t *testing.T) {
+	tests := []struct {
+		name string
+		m    api.Middleware
+	}{
+		{"status codes", middleware.RetryOnStatusCodes(2, middleware.StatusCodeRange{Low: 500, High: 599})},
+		{"backoff", middleware.RetryWithBackoff(2, middleware.ConstantBackoff(0))},
+		{"policies", middleware.RetryWithPolicies(middleware.RetryPolicy{Ranges: []middleware.StatusCodeRange{{Low: 500, High: 599}}, MaxRetries: 2})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodPut, "http://localhost/", strings.NewReader("payload"))
+
+			bodies := []*trackedBody{}
+			tt.m(func(req *http.Request) (*http.Response, error) {
+				for i, b := range bodies {
+					if !b.closed {
+						t.Errorf("response %d still open when the request was sent again", i)
+					}
+				}
+
+				b := &trackedBody{Reader: strings.NewReader("error")}
+				bodies = append(bodies, b)
+				return &http.Response{StatusCode: 503, Body: b}, nil
+			})(req)
+
+			if len(bodies) != 3 {
+				t.Errorf("tries: want 3 got %d", len(bodies))
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// ErrRetryBudgetExhausted is wrapped in an ErrMaxRetries when a retry is denied by the RetryBudget
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBudgetSlots is how many slots the sliding window is split in to
+const retryBudgetSlots = 10
+
+// RetryBudget limits the retries across all the requests of a client so an
+// outage upstream doesn't get multiplied by the retries.
+//
+// Over the sliding window, the retries can't be more than ratio of the
+// requests plus minPerSecond for each second of the window, which lets
+// a client with little traffic still retry.
+type RetryBudget struct {
+	ratio        float64
+	minPerSecond float64
+	window       time.Duration
+
+	mu    sync.Mutex
+	slots [retryBudgetSlots]retryBudgetSlot
+}
+
+// retryBudgetSlot counts the requests and retries for a slice of the window
+type retryBudgetSlot struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// NewRetryBudget creates a RetryBudget. For example NewRetryBudget(0.1, 10, 10*time.Second)
+// allows retries of up to 10% of the requests over the last 10 seconds plus 10 a second.
+func NewRetryBudget(ratio, minPerSecond float64, window time.Duration) *RetryBudget {
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+
+	// each slot needs to be at least a nanosecond
+	if window < retryBudgetSlots {
+		window = retryBudgetSlots
+	}
+
+	return &RetryBudget{
+		ratio:        ratio,
+		minPerSecond: minPerSecond,
+		window:       window,
+	}
+}
+
+// Request records a request.
+// A nil RetryBudget doesn't record anything.
+func (b *RetryBudget) Request() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.slot(time.Now()).requests++
+}
+
+// Withdraw takes a retry from the budget, reporting false if there are none left.
+// A nil RetryBudget always allows the retry.
+func (b *RetryBudget) Withdraw() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	requests, retries := 0, 0
+	for _, s := range b.slots {
+		if now.Sub(s.start) < b.window {
+			requests += s.requests
+			retries += s.retries
+		}
+	}
+
+	allowed := b.ratio*float64(requests) + b.minPerSecond*b.window.Seconds()
+	if float64(retries) >= allowed {
+		return false
+	}
+
+	b.slot(now).retries++
+
+	return true
+}
+
+// slot returns the slot for the time, clearing it if it is from an earlier window
+func (b *RetryBudget) slot(now time.Time) *retryBudgetSlot {
+	size := b.window / retryBudgetSlots
+	start := now.Truncate(size)
+
+	s := &b.slots[(start.UnixNano()/int64(size))%retryBudgetSlots]
+	if !s.start.Equal(start) {
+		*s = retryBudgetSlot{start: start}
+	}
+
+	return s
+}
+
+// retryBudgetKey is the context key for the RetryBudget
+type retryBudgetKey struct{}
+
+// ContextWithRetryBudget returns a copy of the context carrying the budget
+// for the retry middlewares to withdraw from
+func ContextWithRetryBudget(ctx context.Context, b *RetryBudget) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, b)
+}
+
+// retryBudget returns the RetryBudget carried on the request, nil if there isn't one.
+// It is safe to call with a nil request.
+func retryBudget(req *http.Request) *RetryBudget {
+	if req == nil {
+		return nil
+	}
+
+	b, _ := req.Context().Value(retryBudgetKey{}).(*RetryBudget)
+	return b
+}
+
+// UseRetryBudget is a Do func middleware that records each request in the
+// budget and carries it on the request for the retry middlewares after it.
+// When a retry is denied they return ErrMaxRetries{ErrRetryBudgetExhausted}.
+//
+//	budget := NewRetryBudget(0.1, 10, 10*time.Second)
+//	api.WithMiddleware(UseRetryBudget(budget), RetryWithDelay(...))
+func UseRetryBudget(b *RetryBudget) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			b.Request()
+
+			return next(req.WithContext(ContextWithRetryBudget(req.Context(), b)))
+		}
+
+	}
+}
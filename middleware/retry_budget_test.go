@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestRetryBudget(t *testing.T) {
+	b := middleware.NewRetryBudget(0.1, 0, time.Minute)
+
+	for i := 0; i < 20; i++ {
+		b.Request()
+	}
+
+	if !b.Withdraw() || !b.Withdraw() {
+		t.Errorf("expected 2 retries for 20 requests")
+	}
+
+	if b.Withdraw() {
+		t.Errorf("expected the 3rd retry to be denied")
+	}
+
+	var none *middleware.RetryBudget
+	if !none.Withdraw() {
+		t.Errorf("expected a nil budget to allow the retry")
+	}
+}
+
+func TestRetryBudgetMinPerSecond(t *testing.T) {
+	b := middleware.NewRetryBudget(0, 1, 2*time.Second)
+
+	if !b.Withdraw() || !b.Withdraw() {
+		t.Errorf("expected 2 retries for a 2 second window")
+	}
+
+	if b.Withdraw() {
+		t.Errorf("expected the 3rd retry to be denied")
+	}
+}
+
+func TestUseRetryBudget(t *testing.T) {
+	b := middleware.NewRetryBudget(0.5, 0, time.Minute)
+	tries := 0
+
+	do := middleware.UseRetryBudget(b)(middleware.RetryWithBackoff(5, middleware.ConstantBackoff(0))(func(req *http.Request) (*http.Response, error) {
+		tries++
+		return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+	}))
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+	_, err := do(req)
+
+	// 1 request allows half a retry which rounds up to 1
+	if tries != 2 {
+		t.Errorf("tries: want 2 got %d", tries)
+	}
+
+	var maxErr middleware.ErrMaxRetries
+	if !errors.As(err, &maxErr) || !errors.Is(err, middleware.ErrRetryBudgetExhausted) {
+		t.Errorf("expected ErrMaxRetries{ErrRetryBudgetExhausted}, got %v", err)
+	}
+}
+
+func TestRetryBudgetTinyWindow(t *testing.T) {
+	b := middleware.NewRetryBudget(0.1, 1, 5*time.Nanosecond)
+
+	// the window is too small to split in to slots
+	b.Request()
+	b.Withdraw()
+}
+
+func TestUseRetryBudgetNil(t *testing.T) {
+	tries := 0
+	do := middleware.UseRetryBudget(nil)(middleware.RetryWithBackoff(2, middleware.ConstantBackoff(0))(func(req *http.Request) (*http.Response, error) {
+		tries++
+		return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+	}))
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+	do(req)
+
+	// a nil budget allows every retry
+	if tries != 3 {
+		t.Errorf("tries: want 3 got %d", tries)
+	}
+}
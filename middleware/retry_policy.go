@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// RetryPolicy is how to retry the responses with status codes in its ranges.
+// A policy with no ranges is used for errors from next instead.
+type RetryPolicy struct {
+	Ranges     []StatusCodeRange
+	MaxRetries uint // 0 never retries
+
+	// the backoff delay starts at DelayMin and is multiplied by DelayRamp
+	// for each retry up to DelayMax
+	DelayMin  time.Duration
+	DelayMax  time.Duration
+	DelayRamp float32
+
+	// Backoff replaces the DelayMin, DelayMax and DelayRamp backoff when it is set
+	Backoff Backoff
+
+	// MaxRetryAfter caps the delay asked for by a Retry-After header.
+	// If it is 0 the delay is capped at DelayMax when there is no Backoff,
+	// and not capped at all when DelayMax is 0 too.
+	MaxRetryAfter time.Duration
+
+	// AttemptTimeout is the timeout for each retry under the policy, none if 0.
+	// The first attempt, before a policy is known, uses the largest AttemptTimeout
+	// of the policies. The api.AttemptTimeout request option overrides it.
+	AttemptTimeout time.Duration
+}
+
+// delay returns the delay before the nth retry (starting at 0) under the policy.
+// The Retry-After header of the response is used when there is one.
+func (p RetryPolicy) delay(n uint, prev time.Duration, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			max := p.MaxRetryAfter
+			if max == 0 && p.Backoff == nil {
+				max = p.DelayMax
+			}
+			if max > 0 && wait > max {
+				wait = max
+			}
+			return wait
+		}
+	}
+
+	if p.Backoff != nil {
+		return p.Backoff.Delay(n, prev)
+	}
+
+	ramp := float64(p.DelayRamp)
+	if ramp < 1 {
+		ramp = 1
+	}
+
+	return exponential(p.DelayMin, p.DelayMax, ramp, n)
+}
+
+// matchPolicy returns the index of the first policy for the result of an attempt
+func matchPolicy(policies []RetryPolicy, resp *http.Response, err error) (int, bool) {
+	for i, p := range policies {
+		if err != nil || resp == nil {
+			if len(p.Ranges) == 0 {
+				return i, true
+			}
+			continue
+		}
+
+		if InRanges(resp.StatusCode, p.Ranges) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// RetryWithPolicies is a Do func middleware that retries using the first policy
+// whose ranges match the status code, or the first policy without ranges when
+// next returns an error. Each policy keeps its own retry count and backoff.
+// A Retry-After header (in seconds or as an HTTP date) on the response is
+// used as the delay, capped by the policy's MaxRetryAfter.
+//
+// For example, aggressive for 503, patient for 429 and never for 501:
+//
+//	RetryWithPolicies(
+//		RetryPolicy{Ranges: []StatusCodeRange{{501, 501}}},
+//		RetryPolicy{Ranges: []StatusCodeRange{{503, 503}}, MaxRetries: 5, DelayMin: 50 * time.Millisecond, DelayMax: time.Second, DelayRamp: 2},
+//		RetryPolicy{Ranges: []StatusCodeRange{{429, 429}}, MaxRetries: 3, DelayMin: time.Second, DelayMax: 10 * time.Second, DelayRamp: 2, MaxRetryAfter: time.Minute},
+//	)
+func RetryWithPolicies(policies ...RetryPolicy) api.Middleware {
+	// the first attempt gets the longest of the timeouts
+	firstTimeout := time.Duration(0)
+	for _, p := range policies {
+		if p.AttemptTimeout > firstTimeout {
+			firstTimeout = p.AttemptTimeout
+		}
+	}
+
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			if requestOptions(req).DisableRetries || !retryable(req) {
+				return sendAttempt(next, req, 1, firstTimeout)
+			}
+
+			// If there is an error, resp can be nil
+			resp, err := sendAttempt(next, req, 1, firstTimeout)
+
+			retries := make([]uint, len(policies))
+			delays := make([]time.Duration, len(policies))
+			retryCount := 0
+			for {
+				i, ok := matchPolicy(policies, resp, err)
+				if !ok || policies[i].MaxRetries == 0 {
+					return resp, err
+				}
+
+				if retries[i] >= policies[i].MaxRetries {
+					break
+				}
+
+				delay := policies[i].delay(retries[i], delays[i], resp)
+				delays[i] = delay
+				retries[i]++
+				retryCount++
+				if err := withdrawRetry(req); err != nil {
+					return resp, err
+				}
+				if err := prepareRetry(req, resp, retryCount, delay); err != nil {
+					return nil, err
+				}
+
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(delay):
+					// try again
+					resp, err = sendAttempt(next, req, retryCount+1, policies[i].AttemptTimeout)
+				}
+			}
+
+			if err != nil || resp == nil {
+				return resp, ErrMaxRetries{err}
+			}
+
+			return resp, ErrMaxRetries{ErrStatusCode{resp.Status, resp.StatusCode}}
+		}
+
+	}
+}
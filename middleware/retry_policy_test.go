@@ -0,0 +1,86 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestRetryWithPolicies(t *testing.T) {
+	policies := []middleware.RetryPolicy{
+		{Ranges: []middleware.StatusCodeRange{{Low: 501, High: 501}}},
+		{Ranges: []middleware.StatusCodeRange{{Low: 503, High: 503}}, MaxRetries: 4, DelayMin: time.Millisecond, DelayMax: time.Millisecond, DelayRamp: 2},
+		{Ranges: []middleware.StatusCodeRange{{Low: 429, High: 429}}, MaxRetries: 1, DelayMin: time.Millisecond, DelayMax: time.Millisecond, MaxRetryAfter: 50 * time.Millisecond},
+	}
+
+	tests := []struct {
+		name      string
+		status    int
+		header    http.Header
+		wantTries int
+		wantErr   bool
+		minTime   time.Duration
+	}{
+		{"never", 501, nil, 1, false, 0},
+		{"aggressive", 503, nil, 5, true, 0},
+		{"retry after seconds", 429, http.Header{"Retry-After": {"0"}}, 2, true, 0},
+		{"retry after capped", 429, http.Header{"Retry-After": {"3600"}}, 2, true, 50 * time.Millisecond},
+		{"retry after date", 429, http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}, 2, true, 50 * time.Millisecond},
+		{"not matched", 500, nil, 1, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "", nil)
+
+			tries := 0
+			start := time.Now()
+			_, err := middleware.RetryWithPolicies(policies...)(func(req *http.Request) (*http.Response, error) {
+				tries++
+				return &http.Response{StatusCode: tt.status, Header: tt.header, Body: http.NoBody}, nil
+			})(req)
+
+			if tries != tt.wantTries {
+				t.Errorf("tries: want %d got %d", tt.wantTries, tries)
+			}
+
+			var maxErr middleware.ErrMaxRetries
+			if tt.wantErr != errors.As(err, &maxErr) {
+				t.Errorf("expected ErrMaxRetries %v, got %v", tt.wantErr, err)
+			}
+
+			elapsed := time.Since(start)
+			if elapsed < tt.minTime {
+				t.Errorf("expected a delay of at least %v, took %v", tt.minTime, elapsed)
+			}
+			if elapsed > time.Second {
+				t.Errorf("expected the Retry-After to be capped, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryWithPoliciesErrors(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "", nil)
+
+	tries := 0
+	_, err := middleware.RetryWithPolicies(
+		middleware.RetryPolicy{MaxRetries: 2, DelayMin: time.Millisecond, DelayMax: time.Millisecond},
+	)(func(req *http.Request) (*http.Response, error) {
+		tries++
+		return nil, errors.New("connection reset")
+	})(req)
+
+	if tries != 3 {
+		t.Errorf("tries: want 3 got %d", tries)
+	}
+
+	var maxErr middleware.ErrMaxRetries
+	if !errors.As(err, &maxErr) {
+		t.Errorf("expected ErrMaxRetries, got %v", err)
+	}
+}
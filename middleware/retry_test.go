@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Reisender/go-api"
 	"github.com/Reisender/go-api/middleware"
 )
 
@@ -91,3 +92,21 @@ func TestRetryWithDelay(t *testing.T) {
 		t.Errorf("expected %d retries, instead got %d", retries+1, tryCount)
 	}
 }
+
+func TestRetryDisabledByRequestOptions(t *testing.T) {
+	ctx := api.WithRequestOptions(context.Background(), api.DisableRetries())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "", nil)
+
+	m := middleware.RetryWithDelay(retries, time.Millisecond, time.Millisecond, 1)
+	tryCount := 0
+	m(func(req *http.Request) (*http.Response, error) {
+		tryCount++
+		return &http.Response{
+			StatusCode: 500,
+		}, nil
+	})(req)
+
+	if tryCount != 1 {
+		t.Errorf("expected %d try, instead got %d", 1, tryCount)
+	}
+}
@@ -0,0 +1,323 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// Signer signs a request.
+// The body is the full request body, nil when there isn't one, so the
+// signer doesn't need to read it.
+type Signer interface {
+	Sign(req *http.Request, body []byte, now time.Time) error
+}
+
+// Sign is a Do func middleware that signs each request with the signer.
+// The body is read through GetBody when it is set. Otherwise it is
+// buffered and GetBody is set so the body can be read again by
+// later middleware like the retries.
+func Sign(s Signer) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			body, err := readBody(req)
+			if err != nil {
+				return nil, err
+			}
+
+			err = s.Sign(req, body, time.Now())
+			if err != nil {
+				return nil, err
+			}
+
+			return next(req)
+		}
+
+	}
+}
+
+// readBody returns the request body without consuming it
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		return io.ReadAll(rc)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return body, nil
+}
+
+// HMACSigner signs requests with an HMAC over a canonical form of the request.
+//
+// The canonical request is the method, the path, the sorted query, the signed
+// headers as lowercase "name:value" lines, the signed header names joined
+// with ";" and the hex SHA-256 of the body, each on its own line.
+// The string to sign is the algorithm, the Date header and the hex SHA-256
+// of the canonical request, again on their own lines.
+//
+// The Date and X-Content-SHA256 headers are set and the Authorization header is
+//
+//	<Algorithm> KeyId=<KeyID>, SignedHeaders=<names>, Signature=<hex hmac>
+type HMACSigner struct {
+	KeyID         string
+	Secret        []byte
+	Algorithm     string           // name in the Authorization header, "HMAC-SHA256" if empty
+	Hash          func() hash.Hash // hash for the HMAC, sha256.New if nil
+	SignedHeaders []string         // "host", "date" and "x-content-sha256" if empty
+}
+
+// Sign implements the Signer interface
+func (s HMACSigner) Sign(req *http.Request, body []byte, now time.Time) error {
+	algorithm := s.Algorithm
+	if algorithm == "" {
+		algorithm = "HMAC-SHA256"
+	}
+
+	newHash := s.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+
+	signed := s.SignedHeaders
+	if len(signed) == 0 {
+		signed = []string{"host", "date", "x-content-sha256"}
+	}
+
+	date := now.UTC().Format(http.TimeFormat)
+	bodyHash := hexSHA256(body)
+	req.Header.Set("Date", date)
+	req.Header.Set("X-Content-SHA256", bodyHash)
+
+	headers, names := canonicalHeaders(req, signed)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		names,
+		bodyHash,
+	}, "\n")
+
+	toSign := strings.Join([]string{algorithm, date, hexSHA256([]byte(canonical))}, "\n")
+
+	mac := hmac.New(newHash, s.Secret)
+	mac.Write([]byte(toSign))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s KeyId=%s, SignedHeaders=%s, Signature=%s", algorithm, s.KeyID, names, signature))
+
+	return nil
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4.
+// The host and X-Amz-Date headers are always signed, along with
+// X-Amz-Security-Token when there is a SessionToken.
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+	SignedHeaders   []string // extra headers to sign, like "content-type"
+	ContentSHA256   bool     // set the X-Amz-Content-Sha256 header, needed for S3
+	KeepPath        bool     // sign the path without normalizing it, needed for S3
+}
+
+// Sign implements the Signer interface
+func (s SigV4Signer) Sign(req *http.Request, body []byte, now time.Time) error {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	bodyHash := hexSHA256(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	signed := append([]string{"host", "x-amz-date"}, s.SignedHeaders...)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		signed = append(signed, "x-amz-security-token")
+	}
+	if s.ContentSHA256 {
+		req.Header.Set("X-Amz-Content-Sha256", bodyHash)
+		signed = append(signed, "x-amz-content-sha256")
+	}
+
+	// every service but S3 signs the normalized path
+	u := *req.URL
+	if !s.KeepPath {
+		u.Path = normalizePath(u.Path)
+	}
+
+	headers, names := canonicalHeaders(req, signed)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(&u),
+		canonicalQuery(&u),
+		headers,
+		names,
+		bodyHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKeyID, scope, names, signature))
+
+	return nil
+}
+
+// canonicalPath is the URI encoded path, "/" when it is empty
+func canonicalPath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(u.Path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery is the URI encoded query sorted by the encoded key and then value
+func canonicalQuery(u *url.URL) string {
+	type pair struct{ k, v string }
+
+	pairs := []pair{}
+	for k, vals := range u.Query() {
+		for _, v := range vals {
+			pairs = append(pairs, pair{uriEncode(k), uriEncode(v)})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].k != pairs[j].k {
+			return pairs[i].k < pairs[j].k
+		}
+		return pairs[i].v < pairs[j].v
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p.k + "=" + p.v
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// normalizePath removes the empty, "." and ".." segments of the path
+// keeping a trailing slash
+func normalizePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	clean := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+
+	return clean
+}
+
+// canonicalHeaders returns the "name:value" lines for the headers
+// and the sorted names joined with ";"
+func canonicalHeaders(req *http.Request, signed []string) (string, string) {
+	names := make([]string, 0, len(signed))
+	seen := map[string]bool{}
+	for _, name := range signed {
+		name = strings.ToLower(name)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	lines := strings.Builder{}
+	for _, name := range names {
+		var vals []string
+		if name == "host" {
+			vals = []string{req.Host}
+			if req.Host == "" {
+				vals = []string{req.URL.Host}
+			}
+		} else {
+			vals = append([]string(nil), req.Header.Values(name)...)
+		}
+
+		for i, v := range vals {
+			// trim and collapse the spaces
+			vals[i] = strings.Join(strings.Fields(v), " ")
+		}
+
+		lines.WriteString(name + ":" + strings.Join(vals, ",") + "\n")
+	}
+
+	return lines.String(), strings.Join(names, ";")
+}
+
+// uriEncode encodes everything but the RFC 3986 unreserved characters
+func uriEncode(s string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
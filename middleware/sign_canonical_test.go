@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"a.b=1&a=1", "a=1&a.b=1"},
+		{"b=2&a=2&a=1", "a=1&a=2&b=2"},
+		{"a%20b=c d", "a%20b=c%20d"},
+	}
+
+	for _, tt := range tests {
+		if got := canonicalQuery(&url.URL{RawQuery: tt.query}); got != tt.want {
+			t.Errorf("%s: want %s got %s", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"//", "/"},
+		{"/./", "/"},
+		{"/example/..", "/"},
+		{"/example1/example2/../..", "/"},
+		{"/a//b/./c/", "/a/b/c/"},
+		{"/a/b", "/a/b"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizePath(tt.path); got != tt.want {
+			t.Errorf("%s: want %s got %s", tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+// the credentials and date from the AWS Signature Version 4 test suite
+var sigV4TestSigner = middleware.SigV4Signer{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	Region:          "us-east-1",
+	Service:         "service",
+}
+
+var sigV4TestDate = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSigV4Signer(t *testing.T) {
+	tests := []struct {
+		name   string
+		signer middleware.SigV4Signer
+		method string
+		url    string
+		header http.Header
+		want   string
+	}{
+		{
+			name:   "get-vanilla",
+			signer: sigV4TestSigner,
+			method: http.MethodGet,
+			url:    "https://example.amazonaws.com/",
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "get-vanilla-query-order-key-case",
+			signer: sigV4TestSigner,
+			method: http.MethodGet,
+			url:    "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:   "get-relative",
+			signer: sigV4TestSigner,
+			method: http.MethodGet,
+			url:    "https://example.amazonaws.com/example/..",
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "get-relative-relative",
+			signer: sigV4TestSigner,
+			method: http.MethodGet,
+			url:    "https://example.amazonaws.com/example1/example2/../..",
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "get-slash",
+			signer: sigV4TestSigner,
+			method: http.MethodGet,
+			url:    "https://example.amazonaws.com//",
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "get-slash-dot-slash",
+			signer: sigV4TestSigner,
+			method: http.MethodGet,
+			url:    "https://example.amazonaws.com/./",
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "iam-list-users",
+			signer: middleware.SigV4Signer{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+				Region:          "us-east-1",
+				Service:         "iam",
+				SignedHeaders:   []string{"content-type"},
+			},
+			method: http.MethodGet,
+			url:    "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			header: http.Header{"Content-Type": []string{"application/x-www-form-urlencoded; charset=utf-8"}},
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			if err := tt.signer.Sign(req, nil, sigV4TestDate); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date: want '20150830T123600Z' got '%s'", got)
+			}
+
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization:\nwant '%s'\ngot  '%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSignKeepsBody(t *testing.T) {
+	signer := middleware.HMACSigner{KeyID: "key", Secret: []byte("secret")}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/items?b=2&a=1", io.NopCloser(strings.NewReader("payload")))
+	req.GetBody = nil // make the middleware buffer the body
+
+	var auth string
+	_, err := middleware.Sign(signer)(func(req *http.Request) (*http.Response, error) {
+		auth = req.Header.Get("Authorization")
+
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != "payload" {
+			t.Errorf("body: want 'payload' got '%s'", body)
+		}
+
+		if req.GetBody == nil {
+			t.Error("expected GetBody to be set")
+		}
+
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(auth, "HMAC-SHA256 KeyId=key, SignedHeaders=date;host;x-content-sha256, Signature=") {
+		t.Errorf("unexpected Authorization '%s'", auth)
+	}
+
+	// sha256 of "payload"
+	want := "239f59ed55e737c77147cf55ad0c1b030b6d7ee748a7426952f9b852d5a935e5"
+	if got := req.Header.Get("X-Content-SHA256"); got != want {
+		t.Errorf("X-Content-SHA256: want '%s' got '%s'", want, got)
+	}
+}
+
+func TestHMACSignerIsDeterministic(t *testing.T) {
+	signer := middleware.HMACSigner{KeyID: "key", Secret: []byte("secret")}
+
+	sign := func(rawURL string) string {
+		req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+		if err := signer.Sign(req, nil, sigV4TestDate); err != nil {
+			t.Fatal(err)
+		}
+		return req.Header.Get("Authorization")
+	}
+
+	// the query is sorted so the order doesn't change the signature
+	if sign("https://example.com/items?b=2&a=1") != sign("https://example.com/items?a=1&b=2") {
+		t.Error("expected the same signature for reordered query params")
+	}
+
+	if sign("https://example.com/items?a=1") == sign("https://example.com/items?a=2") {
+		t.Error("expected different signatures for different queries")
+	}
+}
@@ -54,6 +54,11 @@ func ErrorOnStatusCodes(statusCodes ...StatusCodeRange) api.Middleware {
 				return res, err
 			}
 
+			// the request asked for the status codes to be left alone
+			if requestOptions(req).SkipStatusErrors {
+				return res, err
+			}
+
 			// now see if it is an error code to convert to error
 			if InRanges(res.StatusCode, statusCodes) {
 				return res, ErrStatusCode{res.Status, res.StatusCode}
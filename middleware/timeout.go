@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// ErrAttemptTimeout is the error when an attempt at a request takes longer
+// than its timeout while the caller's context is still fine. When the
+// caller's deadline is exceeded its context error is returned instead.
+type ErrAttemptTimeout struct {
+	Duration time.Duration // the timeout of the attempt
+}
+
+// Error implements the error interface
+func (e ErrAttemptTimeout) Error() string {
+	return fmt.Sprintf("attempt timed out after %s", e.Duration)
+}
+
+// Timeout reports that it is a timeout like the net.Error interface
+func (e ErrAttemptTimeout) Timeout() bool {
+	return true
+}
+
+// Unwrap makes errors.Is(err, context.DeadlineExceeded) true
+func (e ErrAttemptTimeout) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Timeout is a Do func middleware that gives each call to next its own
+// timeout. The api.AttemptTimeout request option overrides it. Put it after
+// the retry middlewares to time out each attempt separately.
+func Timeout(timeout time.Duration) api.Middleware {
+	// return the middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return the Do func
+		return func(req *http.Request) (*http.Response, error) {
+			return sendAttempt(next, req, 0, timeout)
+		}
+
+	}
+}
+
+// sendAttempt calls next with a child context of the request that times out
+// after the timeout, or the api.AttemptTimeout request option when it is set.
+// There is no timeout if both are 0. Attempts after the first are recorded on
+// the context for Attempt. It is safe to call with a nil request.
+func sendAttempt(next api.Dofn, req *http.Request, attempt int, timeout time.Duration) (*http.Response, error) {
+	if req == nil {
+		return next(req)
+	}
+
+	if attempt > 1 {
+		req = req.WithContext(context.WithValue(req.Context(), attemptKey{}, attempt))
+	}
+
+	if ro := requestOptions(req); ro.AttemptTimeout > 0 {
+		timeout = ro.AttemptTimeout
+	}
+
+	if timeout <= 0 {
+		return next(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := next(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && req.Context().Err() == nil {
+			err = ErrAttemptTimeout{timeout}
+		}
+
+		return resp, err
+	}
+
+	api.CancelOnClose(resp, cancel)
+
+	return resp, nil
+}
+
+// attemptKey is the context key for the attempt number
+type attemptKey struct{}
+
+// Attempt returns which attempt at the request it is, starting at 1, as
+// recorded by the retry middlewares. It is safe to call with a nil request.
+func Attempt(req *http.Request) int {
+	if req == nil {
+		return 1
+	}
+
+	attempt, ok := req.Context().Value(attemptKey{}).(int)
+	if !ok {
+		return 1
+	}
+
+	return attempt
+}
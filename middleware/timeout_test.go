@@ -0,0 +1,173 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+// slow waits for the delay or the request to be canceled
+func slow(delay time.Duration) api.Dofn {
+	return func(req *http.Request) (*http.Response, error) {
+		select {
+		case <-time.After(delay):
+			return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+
+	_, err := middleware.Timeout(10 * time.Millisecond)(slow(time.Second))(req)
+
+	var timeout middleware.ErrAttemptTimeout
+	if !errors.As(err, &timeout) || timeout.Duration != 10*time.Millisecond {
+		t.Errorf("expected ErrAttemptTimeout, got %v", err)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error to be a DeadlineExceeded")
+	}
+
+	resp, err := middleware.Timeout(time.Second)(slow(time.Millisecond))(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestTimeoutCallerDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/", nil)
+
+	_, err := middleware.Timeout(time.Second)(slow(time.Second))(req)
+
+	var timeout middleware.ErrAttemptTimeout
+	if errors.As(err, &timeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the caller's DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	ctx := api.WithRequestOptions(context.Background(), api.AttemptTimeout(10*time.Millisecond))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/", nil)
+
+	tries := 0
+	resp, err := middleware.RetryWithBackoff(2, middleware.ConstantBackoff(0))(func(req *http.Request) (*http.Response, error) {
+		tries++
+		if tries == 1 {
+			return slow(time.Second)(req)
+		}
+		return slow(time.Millisecond)(req)
+	})(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if tries != 2 {
+		t.Errorf("tries: want 2 got %d", tries)
+	}
+}
+
+func TestRetryPolicyAttemptTimeout(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+
+	tries := 0
+	start := time.Now()
+	_, err := middleware.RetryWithPolicies(
+		middleware.RetryPolicy{Ranges: []middleware.StatusCodeRange{{Low: 503, High: 503}}, MaxRetries: 1, AttemptTimeout: 10 * time.Millisecond},
+		middleware.RetryPolicy{},
+	)(func(req *http.Request) (*http.Response, error) {
+		tries++
+		if tries == 1 {
+			return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+		}
+		return slow(time.Second)(req)
+	})(req)
+
+	var timeout middleware.ErrAttemptTimeout
+	if !errors.As(err, &timeout) {
+		t.Errorf("expected ErrAttemptTimeout, got %v", err)
+	}
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected the retry to time out, took %v", time.Since(start))
+	}
+}
+
+func TestRetryPolicyFirstAttemptTimeout(t *testing.T) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+
+	tries := 0
+	start := time.Now()
+	resp, err := middleware.RetryWithPolicies(
+		middleware.RetryPolicy{MaxRetries: 1, AttemptTimeout: 10 * time.Millisecond},
+	)(func(req *http.Request) (*http.Response, error) {
+		tries++
+		if tries == 1 {
+			return slow(300 * time.Millisecond)(req)
+		}
+		return slow(time.Millisecond)(req)
+	})(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if tries != 2 {
+		t.Errorf("tries: want 2 got %d", tries)
+	}
+
+	if time.Since(start) > 200*time.Millisecond {
+		t.Errorf("expected the first attempt to time out, took %v", time.Since(start))
+	}
+}
+
+func TestRetryTimeoutMiddlewares(t *testing.T) {
+	tests := []struct {
+		name string
+		m    api.Middleware
+	}{
+		{"status codes", middleware.RetryOnStatusCodesTimeout(1, 10*time.Millisecond, middleware.StatusCodeRange{Low: 500, High: 599})},
+		{"delay", middleware.RetryWithDelayTimeout(1, 10*time.Millisecond, time.Millisecond, time.Millisecond, 1)},
+		{"backoff", middleware.RetryWithBackoffTimeout(1, 10*time.Millisecond, middleware.ConstantBackoff(0))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/", nil)
+
+			tries := 0
+			start := time.Now()
+			resp, err := tt.m(func(req *http.Request) (*http.Response, error) {
+				tries++
+				if tries == 1 {
+					return slow(300 * time.Millisecond)(req)
+				}
+				return slow(time.Millisecond)(req)
+			})(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if tries != 2 {
+				t.Errorf("tries: want 2 got %d", tries)
+			}
+
+			if time.Since(start) > 200*time.Millisecond {
+				t.Errorf("expected the first attempt to time out, took %v", time.Since(start))
+			}
+		})
+	}
+}
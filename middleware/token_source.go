@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Reisender/go-api"
+)
+
+// TokenSource provides bearer tokens and the time they expire.
+// A zero expiry means the token doesn't expire.
+type TokenSource interface {
+	Token(ctx context.Context) (string, time.Time, error)
+}
+
+// Invalidator is implemented by token sources that can drop a token
+// the server rejected so the next call to Token gets a new one.
+type Invalidator interface {
+	Invalidate(token string)
+}
+
+// TokenSourceFunc allows a func to be used as a TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, time.Time, error)
+
+// Token implements the TokenSource interface
+func (f TokenSourceFunc) Token(ctx context.Context) (string, time.Time, error) {
+	return f(ctx)
+}
+
+// StaticToken is a TokenSource for a token that never changes
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (string, time.Time, error) {
+		return token, time.Time{}, nil
+	})
+}
+
+// EnvToken is a TokenSource that reads the token from the environment
+// variable each time it is called.
+func EnvToken(name string) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (string, time.Time, error) {
+		token := strings.TrimSpace(os.Getenv(name))
+		if token == "" {
+			return "", time.Time{}, fmt.Errorf("token: environment variable %s is not set", name)
+		}
+		return token, time.Time{}, nil
+	})
+}
+
+// FileToken is a TokenSource that reads the token from a file.
+// The file is checked on each call and read again when its size or
+// modification time changes, so tokens rotated on disk are picked up.
+func FileToken(path string) TokenSource {
+	return &fileToken{path: path}
+}
+
+type fileToken struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+func (ft *fileToken) Token(ctx context.Context) (string, time.Time, error) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	info, err := os.Stat(ft.path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if ft.token != "" && info.ModTime().Equal(ft.modTime) && info.Size() == ft.size {
+		return ft.token, time.Time{}, nil
+	}
+
+	b, err := os.ReadFile(ft.path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", time.Time{}, fmt.Errorf("token: file %s is empty", ft.path)
+	}
+
+	ft.token, ft.modTime, ft.size = token, info.ModTime(), info.Size()
+
+	return ft.token, time.Time{}, nil
+}
+
+// Invalidate makes the next call read the file again
+func (ft *fileToken) Invalidate(token string) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	if ft.token == token {
+		ft.token = ""
+	}
+}
+
+// CachedToken wraps the source and caches its token until expiryDelta before
+// it expires. The source is called under a lock so concurrent callers share
+// one call to it. Invalidate drops the cached token.
+func CachedToken(src TokenSource, expiryDelta time.Duration) TokenSource {
+	return &cachedToken{src: src, delta: expiryDelta}
+}
+
+type cachedToken struct {
+	src   TokenSource
+	delta time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (ct *cachedToken) Token(ctx context.Context) (string, time.Time, error) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if ct.token != "" && (ct.expiry.IsZero() || time.Now().Add(ct.delta).Before(ct.expiry)) {
+		return ct.token, ct.expiry, nil
+	}
+
+	token, expiry, err := ct.src.Token(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	ct.token, ct.expiry = token, expiry
+
+	return ct.token, ct.expiry, nil
+}
+
+// Invalidate drops the cached token if it is still the given one,
+// so a token that was already refreshed by another request is kept.
+// It is passed on to the wrapped source if that is also an Invalidator.
+func (ct *cachedToken) Invalidate(token string) {
+	ct.mu.Lock()
+	if ct.token == token {
+		ct.token = ""
+	}
+	ct.mu.Unlock()
+
+	if inv, ok := ct.src.(Invalidator); ok {
+		inv.Invalidate(token)
+	}
+}
+
+// BearerTokenSource is like BearerToken but gets the token from the source
+// for each request. When a request gets a 401 the token is invalidated,
+// if the source is an Invalidator, and the request is retried once with
+// a new token if its body can be replayed.
+func BearerTokenSource(src TokenSource) api.Middleware {
+	// create a middleware func
+	return func(next api.Dofn) api.Dofn {
+
+		// return a new Dofn
+		return func(req *http.Request) (*http.Response, error) {
+			token, _, err := src.Token(req.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := next(req)
+			if err != nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+
+			// only retry when the body can be sent again
+			if req.Body != nil && req.GetBody == nil {
+				return resp, err
+			}
+
+			if inv, ok := src.(Invalidator); ok {
+				inv.Invalidate(token)
+			}
+
+			fresh, _, err := src.Token(req.Context())
+			if err != nil || fresh == token {
+				return resp, nil // keep the 401 since there is no better answer
+			}
+
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return resp, nil
+				}
+				req.Body = body
+			}
+
+			// done with the 401 response
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			req.Header.Set("Authorization", "Bearer "+fresh)
+			return next(req)
+		}
+
+	}
+}
@@ -0,0 +1,122 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestFileToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	src := middleware.FileToken(path)
+
+	token, _, err := src.Token(context.Background())
+	if err != nil || token != "first" {
+		t.Fatalf("want 'first' got '%s' %v", token, err)
+	}
+
+	// rotate the token on disk
+	if err := os.WriteFile(path, []byte("second-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err = src.Token(context.Background())
+	if err != nil || token != "second-token" {
+		t.Errorf("want 'second-token' got '%s' %v", token, err)
+	}
+}
+
+func TestEnvToken(t *testing.T) {
+	t.Setenv("GO_API_TEST_TOKEN", "from-env")
+
+	token, _, err := middleware.EnvToken("GO_API_TEST_TOKEN").Token(context.Background())
+	if err != nil || token != "from-env" {
+		t.Errorf("want 'from-env' got '%s' %v", token, err)
+	}
+
+	if _, _, err := middleware.EnvToken("GO_API_TEST_TOKEN_MISSING").Token(context.Background()); err == nil {
+		t.Error("expected an error for a missing variable")
+	}
+}
+
+func TestCachedToken(t *testing.T) {
+	calls := 0
+	src := middleware.CachedToken(middleware.TokenSourceFunc(func(ctx context.Context) (string, time.Time, error) {
+		calls++
+		if calls == 1 {
+			return "expiring", time.Now().Add(time.Second), nil
+		}
+		return "fresh", time.Now().Add(time.Hour), nil
+	}), time.Minute)
+
+	ctx := context.Background()
+
+	// the first token expires within the delta so it isn't reused
+	for _, want := range []string{"expiring", "fresh", "fresh"} {
+		token, _, err := src.Token(ctx)
+		if err != nil || token != want {
+			t.Errorf("want '%s' got '%s' %v", want, token, err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls to the source, got %d", calls)
+	}
+
+	src.(middleware.Invalidator).Invalidate("fresh")
+	src.Token(ctx)
+	if calls != 3 {
+		t.Errorf("expected the invalidated token to be fetched again, got %d calls", calls)
+	}
+}
+
+func TestBearerTokenSourceRetriesOn401(t *testing.T) {
+	tokens := []string{"stale", "good"}
+	src := middleware.CachedToken(middleware.TokenSourceFunc(func(ctx context.Context) (string, time.Time, error) {
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token, time.Time{}, nil
+	}), 0)
+
+	sent := []string{}
+	m := middleware.BearerTokenSource(src)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+
+	resp, err := m(func(req *http.Request) (*http.Response, error) {
+		auth := req.Header.Get("Authorization")
+		sent = append(sent, auth)
+		if auth != "Bearer good" {
+			return &http.Response{StatusCode: 401, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 200 || len(sent) != 2 {
+		t.Errorf("expected a retry with the new token, got %d after %v", resp.StatusCode, sent)
+	}
+}
+
+func TestBearerTokenSourceNilResponse(t *testing.T) {
+	m := middleware.BearerTokenSource(middleware.StaticToken("abc"))
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+
+	resp, err := m(func(req *http.Request) (*http.Response, error) {
+		return nil, nil
+	})(req)
+
+	if resp != nil || err != nil {
+		t.Errorf("expected nil and nil, got %v and %v", resp, err)
+	}
+}
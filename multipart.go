@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Multipart builds a multipart/form-data request body that is streamed
+// through an io.Pipe so files are never buffered in memory.
+type Multipart struct {
+	boundary string
+	parts    []part
+	progress func(written int64)
+}
+
+// part is a single field or file of the form
+type part struct {
+	field    string
+	filename string
+	value    string
+	reader   io.Reader                     // a file that can only be read once
+	open     func() (io.ReadCloser, error) // a file that can be opened again for a retry
+}
+
+// NewMultipart creates an empty multipart form with a random boundary
+func NewMultipart() *Multipart {
+	return &Multipart{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+}
+
+// Field adds a form field
+func (m *Multipart) Field(name, value string) *Multipart {
+	m.parts = append(m.parts, part{field: name, value: value})
+	return m
+}
+
+// File adds a file read from r.
+// The reader can only be read once so the request can't be replayed
+// by retry middleware, use FileFunc or FilePath for that.
+func (m *Multipart) File(field, filename string, r io.Reader) *Multipart {
+	m.parts = append(m.parts, part{field: field, filename: filename, reader: r})
+	return m
+}
+
+// FileFunc adds a file that is opened when the body is written.
+// It is opened again each time the body is replayed.
+func (m *Multipart) FileFunc(field, filename string, open func() (io.ReadCloser, error)) *Multipart {
+	m.parts = append(m.parts, part{field: field, filename: filename, open: open})
+	return m
+}
+
+// FilePath adds the file at the path using its base name as the filename
+func (m *Multipart) FilePath(field, path string) *Multipart {
+	return m.FileFunc(field, filepath.Base(path), func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+// OnProgress sets a func that is called with the total bytes of the body
+// written so far as the body is streamed.
+func (m *Multipart) OnProgress(progress func(written int64)) *Multipart {
+	m.progress = progress
+	return m
+}
+
+// ContentType is the Content-Type header value including the boundary
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// Replayable reports if the body can be written more than once
+func (m *Multipart) Replayable() bool {
+	for _, p := range m.parts {
+		if p.reader != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Body returns a reader that streams the encoded form.
+// Nothing is read from the files until the body is read.
+func (m *Multipart) Body() io.ReadCloser {
+	return &pipeBody{write: m.writeTo}
+}
+
+// NewRequest creates the request with the client's NewRequestWithContext
+// and sets the streaming body and Content-Type. GetBody is set when every
+// file can be opened again so retry middleware can replay the upload.
+func (m *Multipart) NewRequest(ctx context.Context, c Client, method, endpoint string) (*http.Request, error) {
+	req, err := c.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// a 0 ContentLength with a body is sent as an unknown length
+	req.Body = m.Body()
+	req.Header.Set("Content-Type", m.ContentType())
+
+	if m.Replayable() {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return m.Body(), nil
+		}
+	}
+
+	return req, nil
+}
+
+// writeTo encodes the form to w
+func (m *Multipart) writeTo(w io.Writer) error {
+	if m.progress != nil {
+		w = &progressWriter{w: w, progress: m.progress}
+	}
+
+	mw := multipart.NewWriter(w)
+	err := mw.SetBoundary(m.boundary)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range m.parts {
+		if p.reader == nil && p.open == nil {
+			err = mw.WriteField(p.field, p.value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = m.writeFile(mw, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// writeFile copies the file of the part in to the writer
+func (m *Multipart) writeFile(mw *multipart.Writer, p part) error {
+	r := p.reader
+	if p.open != nil {
+		rc, err := p.open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		r = rc
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(p.filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(p.field), escapeQuotes(p.filename)))
+	h.Set("Content-Type", contentType)
+
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes the value like mime/multipart does for its headers
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// progressWriter reports the total bytes written
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	progress func(written int64)
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.written += int64(n)
+	pw.progress(pw.written)
+	return n, err
+}
+
+// pipeBody streams what write writes through an io.Pipe.
+// The writing goroutine is only started on the first Read so a body
+// that is closed without being read doesn't leak it.
+type pipeBody struct {
+	write func(w io.Writer) error
+
+	once sync.Once
+	mu   sync.Mutex
+	pr   *io.PipeReader
+}
+
+func (b *pipeBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(b.write(pw))
+		}()
+
+		b.mu.Lock()
+		b.pr = pr
+		b.mu.Unlock()
+	})
+
+	b.mu.Lock()
+	pr := b.pr
+	b.mu.Unlock()
+
+	if pr == nil {
+		// closed before it was read
+		return 0, io.ErrClosedPipe
+	}
+
+	return pr.Read(p)
+}
+
+func (b *pipeBody) Close() error {
+	// stop anything else from starting the writer
+	b.once.Do(func() {})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pr == nil {
+		return nil
+	}
+
+	return b.pr.Close()
+}
@@ -0,0 +1,109 @@
+package api_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Reisender/go-api"
+)
+
+func TestMultipart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("file contents"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if got := r.FormValue("title"); got != "Q3" {
+			t.Errorf("field: want 'Q3' got '%s'", got)
+		}
+
+		f, h, err := r.FormFile("upload")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer f.Close()
+
+		body, _ := io.ReadAll(f)
+		if h.Filename != "report.txt" || string(body) != "file contents" {
+			t.Errorf("file: want 'report.txt' 'file contents' got '%s' '%s'", h.Filename, body)
+		}
+
+		if ct := h.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("file content type: want 'text/plain' got '%s'", ct)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c := api.New(srv.URL)
+
+	var written int64
+	m := api.NewMultipart().
+		Field("title", "Q3").
+		FilePath("upload", path).
+		OnProgress(func(n int64) { written = n })
+
+	req, err := m.NewRequest(context.Background(), c, http.MethodPost, "/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.GetBody == nil {
+		t.Fatal("expected GetBody to be set for a replayable body")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status: want 201 got %d", resp.StatusCode)
+	}
+
+	if written == 0 {
+		t.Error("expected progress to be reported")
+	}
+
+	// the body can be replayed and is the same each time
+	first, _ := req.GetBody()
+	second, _ := req.GetBody()
+	a, _ := io.ReadAll(first)
+	b, _ := io.ReadAll(second)
+	if len(a) == 0 || string(a) != string(b) {
+		t.Error("expected the replayed bodies to match")
+	}
+}
+
+func TestMultipartNotReplayable(t *testing.T) {
+	m := api.NewMultipart().File("upload", "data.bin", strings.NewReader("once"))
+
+	req, err := m.NewRequest(context.Background(), api.New("http://localhost"), http.MethodPost, "/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.GetBody != nil {
+		t.Error("expected GetBody to be nil for a reader that can only be read once")
+	}
+
+	// closing without reading shouldn't block or leak
+	if err := req.Body.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a BaseClient created with New
+type Option func(*config)
+
+// config holds the settings collected from the Options
+type config struct {
+	httpClient *http.Client
+	transport  http.RoundTripper
+	timeout    *time.Duration
+	base       string
+	middleware []Middleware
+	hooks      hooks
+}
+
+// WithHTTPClient uses the given http.Client instead of creating one.
+// The client is not modified, if WithTransport or WithTimeout are also
+// used a copy of the client is made with those settings applied.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cfg *config) {
+		cfg.httpClient = client
+	}
+}
+
+// WithTransport sets the RoundTripper used by the http.Client
+func WithTransport(transport http.RoundTripper) Option {
+	return func(cfg *config) {
+		cfg.transport = transport
+	}
+}
+
+// WithTimeout sets the timeout of the http.Client
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.timeout = &timeout
+	}
+}
+
+// WithBase sets the base endpoint that is added by BaseClient.NewURL
+func WithBase(baseEndpoint string) Option {
+	return func(cfg *config) {
+		cfg.base = baseEndpoint
+	}
+}
+
+// WithMiddleware appends the Middleware to the client's chain.
+// It can be used more than once and the Middleware run in the order
+// they were added.
+func WithMiddleware(doers ...Middleware) Option {
+	return func(cfg *config) {
+		cfg.middleware = append(cfg.middleware, doers...)
+	}
+}
+
+// buildHTTPClient returns the http.Client for the config
+func (cfg *config) buildHTTPClient() *http.Client {
+	if cfg.httpClient == nil {
+		client := &http.Client{Transport: cfg.transport}
+		if cfg.timeout != nil {
+			client.Timeout = *cfg.timeout
+		}
+		return client
+	}
+
+	if cfg.transport == nil && cfg.timeout == nil {
+		return cfg.httpClient
+	}
+
+	// copy so the caller's client isn't changed
+	client := *cfg.httpClient
+	if cfg.transport != nil {
+		client.Transport = cfg.transport
+	}
+	if cfg.timeout != nil {
+		client.Timeout = *cfg.timeout
+	}
+
+	return &client
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestOptions are settings for a single request.
+// They are carried on the request's context and are consulted by the
+// client and the built in middleware.
+type RequestOptions struct {
+	SkipCache        bool          // don't read from or write to the cache
+	DisableRetries   bool          // make a single attempt only
+	SkipStatusErrors bool          // don't convert status codes to errors
+	IdempotencyKey   string        // sent as the Idempotency-Key header by the IdempotencyKey middleware
+	Timeout          time.Duration // overall timeout for the request
+	AttemptTimeout   time.Duration // timeout for each attempt by the retry and Timeout middleware
+	Middleware       []Middleware  // run only for this request
+}
+
+// RequestOption sets a value on the RequestOptions
+type RequestOption func(*RequestOptions)
+
+// requestOptionsKey is the context key for the RequestOptions
+type requestOptionsKey struct{}
+
+// WithRequestOptions returns a copy of the context carrying the options.
+// Options already on the context are kept and the new ones applied on top.
+func WithRequestOptions(ctx context.Context, opts ...RequestOption) context.Context {
+	ro := RequestOptionsFrom(ctx)
+
+	// copy the middleware so contexts don't share a backing array
+	ro.Middleware = append([]Middleware(nil), ro.Middleware...)
+
+	for _, opt := range opts {
+		opt(&ro)
+	}
+
+	return context.WithValue(ctx, requestOptionsKey{}, ro)
+}
+
+// RequestOptionsFrom returns the options carried on the context
+func RequestOptionsFrom(ctx context.Context) RequestOptions {
+	if ctx == nil {
+		return RequestOptions{}
+	}
+
+	ro, _ := ctx.Value(requestOptionsKey{}).(RequestOptions)
+	return ro
+}
+
+// SkipCache skips the Cache middleware for the request
+func SkipCache() RequestOption {
+	return func(ro *RequestOptions) {
+		ro.SkipCache = true
+	}
+}
+
+// DisableRetries makes the retry middleware only do one attempt for the request
+func DisableRetries() RequestOption {
+	return func(ro *RequestOptions) {
+		ro.DisableRetries = true
+	}
+}
+
+// SkipStatusErrors stops ErrorOnStatusCodes from returning an error for the request
+func SkipStatusErrors() RequestOption {
+	return func(ro *RequestOptions) {
+		ro.SkipStatusErrors = true
+	}
+}
+
+// IdempotencyKey sets the key the middleware.IdempotencyKey middleware sends
+// in the Idempotency-Key header of a request that isn't idempotent.
+func IdempotencyKey(key string) RequestOption {
+	return func(ro *RequestOptions) {
+		ro.IdempotencyKey = key
+	}
+}
+
+// RequestTimeout overrides the timeout for the request.
+// It covers the whole call including retries and reading the body.
+func RequestTimeout(timeout time.Duration) RequestOption {
+	return func(ro *RequestOptions) {
+		ro.Timeout = timeout
+	}
+}
+
+// AttemptTimeout overrides the timeout for each attempt at the request
+// made by the retry and Timeout middleware. The RequestTimeout still
+// covers the whole call.
+func AttemptTimeout(timeout time.Duration) RequestOption {
+	return func(ro *RequestOptions) {
+		ro.AttemptTimeout = timeout
+	}
+}
+
+// RequestMiddleware adds Middleware that only runs for the request.
+// It runs before the client's Middleware.
+func RequestMiddleware(doers ...Middleware) RequestOption {
+	return func(ro *RequestOptions) {
+		ro.Middleware = append(ro.Middleware, doers...)
+	}
+}
+
+// requestScoped is the outer most Do func of the BaseClient.
+// It applies the RequestOptions on the request's context.
+func requestScoped(next Dofn) Dofn {
+	return func(req *http.Request) (*http.Response, error) {
+		ro := RequestOptionsFrom(req.Context())
+
+		do := next
+		for i := len(ro.Middleware) - 1; i >= 0; i-- {
+			do = ro.Middleware[i](do)
+		}
+
+		if ro.Timeout <= 0 {
+			return do(req)
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), ro.Timeout)
+		resp, err := do(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return resp, err
+		}
+
+		// the body is read after we return so hold the context open until it is closed
+		CancelOnClose(resp, cancel)
+
+		return resp, nil
+	}
+}
+
+// CancelOnClose calls cancel once when the body of the response is closed,
+// or right away when there is no body. It holds a context, or anything else
+// released by cancel, open while the body is read after a Do func returns.
+func CancelOnClose(resp *http.Response, cancel func()) {
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+}
+
+// cancelOnClose calls cancel once when the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+	once   sync.Once
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(c.cancel)
+	return err
+}
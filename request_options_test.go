@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Reisender/go-api"
+	"github.com/Reisender/go-api/middleware"
+)
+
+func TestRequestOptions(t *testing.T) {
+	var gotHeader, gotKey string
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		gotHeader = req.Header.Get("X-Scoped")
+		gotKey = req.Header.Get("Idempotency-Key")
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: req}, nil
+	})
+
+	c := api.New("http://localhost", api.WithTransport(transport), api.WithMiddleware(middleware.IdempotencyKey()))
+
+	scoped := func(next api.Dofn) api.Dofn {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Scoped", "yes")
+			return next(req)
+		}
+	}
+
+	ctx := api.WithRequestOptions(context.Background(),
+		api.RequestMiddleware(scoped),
+		api.IdempotencyKey("abc"),
+	)
+
+	resp, err := c.Post(ctx, "/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if gotHeader != "yes" {
+		t.Errorf("expected the request middleware to run")
+	}
+	if gotKey != "abc" {
+		t.Errorf("idempotency key: want 'abc' got '%s'", gotKey)
+	}
+
+	// without the options the middleware should not run
+	resp, err = c.Post(context.Background(), "/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if gotHeader != "" || gotKey == "abc" {
+		t.Errorf("expected the request options to only apply to the one request")
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	c := api.New("http://localhost", api.WithTransport(transport))
+
+	ctx := api.WithRequestOptions(context.Background(), api.RequestTimeout(time.Millisecond))
+
+	_, err := c.Get(ctx, "/foo")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+}
+
+func TestWithRequestOptionsMerge(t *testing.T) {
+	ctx := api.WithRequestOptions(context.Background(), api.SkipCache())
+	ctx = api.WithRequestOptions(ctx, api.DisableRetries())
+
+	ro := api.RequestOptionsFrom(ctx)
+	if !ro.SkipCache || !ro.DisableRetries {
+		t.Errorf("expected both options to be set, got %+v", ro)
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// DoCodec encodes body with the codec, sends it to the endpoint with the given
+// method through the client's middleware chain, and decodes the response in to Resp.
+// The response is decoded with the codec for its Content-Type, falling back
+// to the request codec when the Content-Type has no registered codec.
+// A nil body sends the request without a payload and an empty response body
+// returns the zero value of Resp.
+// Decode failures are returned as a ParseError with the raw response body.
+// Status codes are not checked here, use middleware like ErrorOnStatusCodes for that.
+func DoCodec[Req, Resp any](ctx context.Context, c Client, codec Codec, method, endpoint string, body Req) (Resp, error) {
+	var resp Resp
+
+	var payload io.Reader
+	if any(body) != nil {
+		buf := &bytes.Buffer{}
+		err := codec.Encode(buf, body)
+		if err != nil {
+			return resp, err
+		}
+		payload = bytes.NewReader(buf.Bytes())
+	}
+
+	req, err := c.NewRequestWithContext(ctx, method, endpoint, payload)
+	if err != nil {
+		return resp, err
+	}
+
+	req.Header.Set("Accept", codec.MediaType())
+	if payload != nil {
+		req.Header.Set("Content-Type", codec.MediaType())
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return resp, err
+	}
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return resp, nil
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(raw))
+	err = decodeResponse(res, &resp, codec)
+
+	return resp, err
+}
@@ -0,0 +1,69 @@
+package api
+
+import "net/url"
+
+// resolve parses the endpoint and resolves it against the root URL.
+//
+// Absolute endpoints (with a scheme) are returned untouched and scheme
+// relative endpoints (//host/path) take the scheme of the root.
+// Otherwise the endpoint path is joined on to the root path, so a root
+// of "/v1" and an endpoint of "/users" is "/v1/users", and the endpoint
+// query is merged with the root query.
+func resolve(root *url.URL, endpoint string) (*url.URL, error) {
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.IsAbs() {
+		return ref, nil
+	}
+
+	if ref.Host != "" {
+		return root.ResolveReference(ref), nil
+	}
+
+	u := *root
+	if p := ref.EscapedPath(); p != "" {
+		if root.Path == "" {
+			u.Path, u.RawPath = ref.Path, ref.RawPath
+		} else {
+			// JoinPath works on the escaped paths so nothing is double encoded
+			u = *root.JoinPath(p)
+		}
+	}
+
+	u.RawQuery = mergeQuery(root.RawQuery, ref.RawQuery)
+	u.Fragment, u.RawFragment = ref.Fragment, ref.RawFragment
+
+	return &u, nil
+}
+
+// mergeQuery combines the two raw queries.
+// Values in the ref query replace values of the same key in the root query.
+// If only one of them is set it is returned as is so its encoding is kept
+// and queries that can't be parsed are joined without merging.
+func mergeQuery(root, ref string) string {
+	if root == "" {
+		return ref
+	}
+	if ref == "" {
+		return root
+	}
+
+	vals, err := url.ParseQuery(root)
+	if err != nil {
+		return root + "&" + ref
+	}
+
+	refVals, err := url.ParseQuery(ref)
+	if err != nil {
+		return root + "&" + ref
+	}
+
+	for k, v := range refVals {
+		vals[k] = v
+	}
+
+	return vals.Encode()
+}